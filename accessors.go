@@ -0,0 +1,179 @@
+package errors
+
+import (
+	"log/slog"
+)
+
+// HasSeverity is implemented by errors which choose the level they are logged at
+type HasSeverity interface {
+	Severity() slog.Level
+}
+
+// CodeOf returns the code (see WithCode()) of the nearest error in err's tree which
+// has a non-empty code. The tree is walked depth first, starting with err and
+// following all the errors of Join() and Errorf() with more than one %w, in the
+// same order as As(). It returns "" if no error has a code.
+func CodeOf(err error) string {
+	code, _ := find(err, func(e error) (string, bool) {
+		if c, ok := e.(HasCode); ok && c.Code() != "" {
+			return c.Code(), true
+		}
+		return "", false
+	})
+	return code
+}
+
+// KindOf returns the Kind (see WithKind()) of the nearest error in err's tree which
+// has a non-empty Kind, walking the tree in the same order as CodeOf(). It returns
+// "" if no error has a Kind.
+func KindOf(err error) Kind {
+	kind, _ := find(err, func(e error) (Kind, bool) {
+		if k, ok := e.(HasKind); ok && k.Kind() != "" {
+			return k.Kind(), true
+		}
+		return "", false
+	})
+	return kind
+}
+
+// StatusOf returns the HTTP status (see WithStatus()) of the nearest error in err's
+// tree which has a non-zero status, walking the tree in the same order as CodeOf().
+// If no error has a status, it returns the status the Kind of err is mapped to (see
+// StatusForKind()), such that an error with only a Kind reports the same status
+// everywhere. It returns 0 if neither is found.
+func StatusOf(err error) int {
+	if status, ok := find(err, func(e error) (int, bool) {
+		if s, ok := e.(HasStatus); ok && s.Status() != 0 {
+			return s.Status(), true
+		}
+		return 0, false
+	}); ok {
+		return status
+	}
+	return StatusForKind(KindOf(err))
+}
+
+// IDOf returns the id (see WithID()) of the nearest error in err's tree which has
+// a non-empty id, walking the tree in the same order as CodeOf(). It returns ""
+// if no error has an id.
+func IDOf(err error) string {
+	id, _ := find(err, func(e error) (string, bool) {
+		if i, ok := e.(HasID); ok && i.ID() != "" {
+			return i.ID(), true
+		}
+		return "", false
+	})
+	return id
+}
+
+// SeverityOf returns the level err should be logged at. It is chosen from the first of
+//
+//   - the nearest error in err's tree which implements HasSeverity
+//   - slog.LevelWarn if the status of err (see StatusOf()) is in the 4xx range,
+//     as these are considered user errors, including errors with only a Kind
+//   - slog.LevelError for all other errors
+//
+// If err is nil, it returns slog.LevelInfo.
+func SeverityOf(err error) slog.Level {
+	if err == nil {
+		return slog.LevelInfo
+	}
+	if level, ok := find(err, func(e error) (slog.Level, bool) {
+		s, ok := e.(HasSeverity)
+		if !ok {
+			return 0, false
+		}
+		return s.Severity(), true
+	}); ok {
+		return level
+	}
+	return statusSeverity(StatusOf(err))
+}
+
+// statusSeverity returns slog.LevelWarn for user errors in the 4xx range, otherwise slog.LevelError
+func statusSeverity(status int) slog.Level {
+	if status >= 400 && status < 500 {
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// find walks err's tree depth first and returns the value of the first error for which
+// match returns true.
+func find[T any](err error, match func(error) (T, bool)) (T, bool) {
+	var zero T
+	if err == nil {
+		return zero, false
+	}
+	if value, ok := match(err); ok {
+		return value, true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return find(e.Unwrap(), match)
+	case interface{ Unwrap() []error }:
+		for _, child := range e.Unwrap() {
+			if value, ok := find(child, match); ok {
+				return value, true
+			}
+		}
+	}
+	return zero, false
+}
+
+// Select returns the error in err's tree whose code (see WithCode()) appears earliest
+// in codes, for mapping errors to responses when more than one classified error is
+// joined. When more than one error has the same code, the first one visited by
+// WalkTree() wins. If no error has one of the codes, it returns nil.
+//
+//	switch e := errors.Select(err, "quota.exceeded", "user.not_found"); errors.CodeOf(e) {
+//	case "quota.exceeded":
+//		...
+//	}
+func Select(err error, codes ...string) error {
+	var selected error
+	best := len(codes)
+	WalkTree(err, func(e error, _ []int) bool {
+		c, ok := e.(HasCode)
+		if !ok || c.Code() == "" {
+			return true
+		}
+		for i := 0; i < best; i++ {
+			if codes[i] == c.Code() {
+				selected, best = e, i
+				break
+			}
+		}
+		return best != 0
+	})
+	return selected
+}
+
+// MostSevere returns the error in err's tree with the highest severity. Only errors
+// which are classified, by implementing HasSeverity or having a status (see WithStatus()),
+// are considered and the severity of each is determined as by SeverityOf(), using only
+// its own classification. When more than one error has the same severity, the first
+// one visited by WalkTree() wins. If no error is classified, it returns nil.
+func MostSevere(err error) error {
+	var selected error
+	var highest slog.Level
+	WalkTree(err, func(e error, _ []int) bool {
+		level, ok := ownSeverity(e)
+		if ok && (selected == nil || level > highest) {
+			selected, highest = e, level
+		}
+		return true
+	})
+	return selected
+}
+
+// ownSeverity returns the severity of err without considering the rest of the chain
+func ownSeverity(err error) (slog.Level, bool) {
+	if s, ok := err.(HasSeverity); ok {
+		return s.Severity(), true
+	}
+	if s, ok := err.(HasStatus); ok && s.Status() != 0 {
+		return statusSeverity(s.Status()), true
+	}
+	return 0, false
+}
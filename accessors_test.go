@@ -0,0 +1,104 @@
+package errors_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type severityErr struct {
+	level slog.Level
+}
+
+func (e *severityErr) Error() string        { return "severity" }
+func (e *severityErr) Severity() slog.Level { return e.level }
+
+func TestAccessors(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "inner",
+		errors.WithCode("inner.code"),
+		errors.WithStatus(http.StatusNotFound),
+		errors.WithKind("not_found"),
+		errors.WithID("inner-id"))
+	err = errors.WrapOpts(err, "outer", errors.WithCode("outer.code"))
+
+	// The nearest value wins
+	assert.Equal(t, "outer.code", errors.CodeOf(err))
+	assert.Equal(t, http.StatusNotFound, errors.StatusOf(err))
+	assert.Equal(t, errors.Kind("not_found"), errors.KindOf(err))
+	assert.Equal(t, "inner-id", errors.IDOf(err))
+	assert.Equal(t, slog.LevelWarn, errors.SeverityOf(err))
+}
+
+func TestAccessorsJoin(t *testing.T) {
+	first := errors.Wrap(io.EOF, "first")
+	second := errors.WrapOpts(io.EOF, "second",
+		errors.WithCode("second.code"),
+		errors.WithStatus(http.StatusBadGateway))
+	third := errors.WrapOpts(io.EOF, "third", errors.WithCode("third.code"))
+	err := errors.Wrap(errors.Join(first, second, third), "joined")
+
+	assert.Equal(t, "second.code", errors.CodeOf(err))
+	assert.Equal(t, http.StatusBadGateway, errors.StatusOf(err))
+	assert.Equal(t, slog.LevelError, errors.SeverityOf(err))
+}
+
+func TestAccessorsFallback(t *testing.T) {
+	assert.Equal(t, "", errors.CodeOf(io.EOF))
+	assert.Equal(t, 0, errors.StatusOf(io.EOF))
+	assert.Equal(t, 0, errors.StatusOf(nil))
+	assert.Equal(t, errors.Kind(""), errors.KindOf(io.EOF))
+	assert.Equal(t, "", errors.IDOf(nil))
+	assert.Equal(t, slog.LevelError, errors.SeverityOf(io.EOF))
+	assert.Equal(t, slog.LevelInfo, errors.SeverityOf(nil))
+}
+
+func TestSeverityOf(t *testing.T) {
+	err := errors.WrapOpts(&severityErr{level: slog.LevelDebug}, "wrapped",
+		errors.WithStatus(http.StatusInternalServerError))
+	assert.Equal(t, slog.LevelDebug, errors.SeverityOf(err))
+
+	// Without a severity or status, the status of the Kind is used
+	err = errors.WrapOpts(errors.New("x"), "m", errors.WithKind(errors.KindNotFound))
+	assert.Equal(t, slog.LevelWarn, errors.SeverityOf(err))
+	err = errors.WrapOpts(errors.New("x"), "m", errors.WithKind(errors.KindUnavailable))
+	assert.Equal(t, slog.LevelError, errors.SeverityOf(err))
+}
+
+func TestSelect(t *testing.T) {
+	notFound := errors.WrapOpts(io.EOF, "not found", errors.WithCode("user.not_found"))
+	quota := errors.WrapOpts(io.EOF, "quota", errors.WithCode("quota.exceeded"))
+	err := errors.Wrap(errors.Join(notFound, errors.Join(io.EOF, quota)), "batch")
+
+	assert.Equal(t, quota, errors.Select(err, "quota.exceeded", "user.not_found"))
+	assert.Equal(t, notFound, errors.Select(err, "user.not_found", "quota.exceeded"))
+	assert.Equal(t, notFound, errors.Select(err, "unknown", "user.not_found"))
+	assert.Nil(t, errors.Select(err, "unknown"))
+	assert.Nil(t, errors.Select(err))
+	assert.Nil(t, errors.Select(nil, "user.not_found"))
+}
+
+func TestMostSevere(t *testing.T) {
+	notFound := errors.WrapOpts(io.EOF, "not found", errors.WithStatus(http.StatusNotFound))
+	unavailable := errors.WrapOpts(io.EOF, "unavailable", errors.WithStatus(http.StatusServiceUnavailable))
+	debug := &severityErr{level: slog.LevelDebug}
+
+	assert.Equal(t, unavailable, errors.MostSevere(errors.Join(notFound, debug, unavailable)))
+	assert.Equal(t, notFound, errors.MostSevere(errors.Join(debug, notFound)))
+	assert.Equal(t, debug, errors.MostSevere(errors.Wrap(debug, "wrapped")))
+	assert.Nil(t, errors.MostSevere(errors.Join(io.EOF, io.ErrUnexpectedEOF)))
+	assert.Nil(t, errors.MostSevere(nil))
+}
+
+func TestStatusOfKind(t *testing.T) {
+	err := errors.WrapOpts(errors.New("x"), "m", errors.WithKind(errors.KindNotFound))
+	assert.Equal(t, http.StatusNotFound, errors.StatusOf(err))
+	assert.Equal(t, http.StatusNotFound, errors.ToEnvelope(err).Status)
+
+	// An explicit status wins over the status of the Kind
+	err = errors.WrapOpts(err, "outer", errors.WithStatus(http.StatusGone))
+	assert.Equal(t, http.StatusGone, errors.StatusOf(err))
+}
@@ -0,0 +1,47 @@
+package errors
+
+import "time"
+
+// AuditOutcomeFailure is the outcome of every event returned by ToAuditEvent()
+const AuditOutcomeFailure = "failure"
+
+// AuditEvent is an entry of the audit log recording an operation which was attempted
+type AuditEvent struct {
+	Time    time.Time      `json:"time"`
+	Actor   string         `json:"actor"`
+	Action  string         `json:"action"`
+	Outcome string         `json:"outcome"`
+	Reason  string         `json:"reason"`
+	Code    string         `json:"code,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// ToAuditEvent returns the audit event recording that actor failed to perform action
+// because of err, such that failed privileged operations are audited using the same
+// error chain which is logged. The reason is the error message, and the fields are
+// those attached to err after redaction (see RegisterRedactor()). The time is when
+// the error was created if known, otherwise the current time.
+//
+//	if err := s.DeleteAccount(ctx, id); err != nil {
+//		audit.Write(errors.ToAuditEvent(err, user.Email, "account.delete"))
+//	}
+//
+// If err is nil, ToAuditEvent returns nil.
+func ToAuditEvent(err error, actor, action string) *AuditEvent {
+	if err == nil {
+		return nil
+	}
+	created, ok := CreatedAt(err)
+	if !ok {
+		created = NowFunc()
+	}
+	return &AuditEvent{
+		Time:    created,
+		Actor:   actor,
+		Action:  action,
+		Outcome: AuditOutcomeFailure,
+		Reason:  MessageOf(err),
+		Code:    CodeOf(err),
+		Fields:  FieldsOf(err),
+	}
+}
@@ -0,0 +1,41 @@
+package errors_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToAuditEvent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	errors.NowFunc = func() time.Time { return now }
+	defer func() { errors.NowFunc = time.Now }()
+
+	err := errors.WrapOpts(io.EOF, "while deleting account",
+		errors.WithCode("account.delete"),
+		errors.WithFieldsOpt(errors.Fields{"account": "acme"}))
+
+	event := errors.ToAuditEvent(err, "admin@example.com", "account.delete")
+	require.NotNil(t, event)
+	assert.Equal(t, &errors.AuditEvent{
+		Time:    now,
+		Actor:   "admin@example.com",
+		Action:  "account.delete",
+		Outcome: errors.AuditOutcomeFailure,
+		Reason:  "while deleting account: EOF",
+		Code:    "account.delete",
+		Fields:  map[string]any{"account": "acme"},
+	}, event)
+
+	// Errors without a creation time are recorded at the current time
+	event = errors.ToAuditEvent(io.EOF, "admin@example.com", "account.delete")
+	assert.Equal(t, now, event.Time)
+	assert.Equal(t, "EOF", event.Reason)
+	assert.Nil(t, event.Fields)
+
+	assert.Nil(t, errors.ToAuditEvent(nil, "admin@example.com", "account.delete"))
+}
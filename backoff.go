@@ -0,0 +1,116 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// HasBackoff is implemented by errors which carry scheduling hints for retrying
+// a transient failure.
+type HasBackoff interface {
+	// Backoff returns how long the caller should wait before the next attempt
+	Backoff() time.Duration
+	// Attempt returns the number of the attempt which failed
+	Attempt() int
+}
+
+// WithBackoff returns a new error wrapping err with a hint of how long to wait before
+// the next attempt and the number of the attempt which failed. Both are included in
+// the fields of the error as `retry.backoff` and `retry.attempt`, such that they
+// are logged automatically.
+//
+//	for attempt := 1; ; attempt++ {
+//		if err := send(msg); err != nil {
+//			next := policy.Next(attempt)
+//			log(errors.WithBackoff(err, next, attempt))
+//			time.Sleep(next)
+//			continue
+//		}
+//		break
+//	}
+//
+// If err is nil, WithBackoff returns nil.
+func WithBackoff(err error, next time.Duration, attempt int) error {
+	if err == nil {
+		return nil
+	}
+	return &backoff{
+		wrapped: err,
+		next:    next,
+		attempt: attempt,
+	}
+}
+
+// BackoffOf returns the backoff and attempt from the first error in err's chain
+// which carries them. If none is found, it returns false.
+func BackoffOf(err error) (next time.Duration, attempt int, ok bool) {
+	var b HasBackoff
+	if !As(err, &b) {
+		return 0, 0, false
+	}
+	return b.Backoff(), b.Attempt(), true
+}
+
+type backoff struct {
+	wrapped error
+	next    time.Duration
+	attempt int
+}
+
+func (b *backoff) Unwrap() error {
+	return b.wrapped
+}
+
+func (b *backoff) ownFields() map[string]any {
+	return map[string]any{"retry.backoff": b.next, "retry.attempt": b.attempt}
+}
+
+func (b *backoff) message() string { return NoMsg }
+
+func (b *backoff) clone() error {
+	c := *b
+	c.wrapped = Clone(b.wrapped)
+	return &c
+}
+
+func (b *backoff) stripStack() error {
+	c := *b
+	c.wrapped = StripStack(b.wrapped)
+	return &c
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (b *backoff) Cause() error { return b.wrapped }
+
+func (b *backoff) Error() string {
+	return limitMsg(b.wrapped.Error())
+}
+
+func (b *backoff) Backoff() time.Duration {
+	return b.next
+}
+
+func (b *backoff) Attempt() int {
+	return b.attempt
+}
+
+func (b *backoff) HasFields() map[string]any {
+	return mergeFields(b)
+}
+
+func (b *backoff) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		b.writePlus(s)
+		return
+	}
+	_, _ = io.WriteString(s, MessageOf(b))
+}
+
+func (b *backoff) writePlus(w io.Writer) bool {
+	return formatPlus(w, NoMsg, b.wrapped, fmt.Sprintf(" (retry.attempt=%d, retry.backoff=%s)", b.attempt, b.next))
+}
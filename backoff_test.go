@@ -0,0 +1,37 @@
+package errors_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithBackoff(t *testing.T) {
+	err := errors.Fields{"key1": "value1"}.Wrap(io.EOF, "while sending")
+	err = errors.WithBackoff(err, time.Second, 3)
+	err = errors.Wrap(err, "top")
+
+	assert.Equal(t, "top: while sending: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	next, attempt, ok := errors.BackoffOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, next)
+	assert.Equal(t, 3, attempt)
+
+	m := errors.ToMap(err)
+	assert.Equal(t, time.Second, m["retry.backoff"])
+	assert.Equal(t, 3, m["retry.attempt"])
+	assert.Equal(t, "value1", m["key1"])
+
+	assert.Equal(t, "while sending: EOF (key1=value1) (retry.attempt=3, retry.backoff=1s)",
+		fmt.Sprintf("%+v", errors.Unwrap(err)))
+
+	_, _, ok = errors.BackoffOf(io.EOF)
+	assert.False(t, ok)
+	assert.Nil(t, errors.WithBackoff(nil, time.Second, 1))
+}
@@ -0,0 +1,109 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Breadcrumb records a step taken by an operation before it failed
+type Breadcrumb struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// WithBreadcrumb returns err with a timestamped breadcrumb appended, such that long
+// running operations can record the sequence of steps leading up to a failure without
+// nesting a wrapper for every step. If err was returned by WithBreadcrumb the breadcrumb
+// is added to a copy of it instead of adding another wrapper.
+//
+//	err = errors.WithBreadcrumb(err, "fetched 200 rows")
+//	err = errors.WithBreadcrumb(err, "uploaded batch 1")
+//
+// If err is nil, WithBreadcrumb returns nil.
+func WithBreadcrumb(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	crumb := Breadcrumb{Time: NowFunc(), Message: msg}
+	if e, ok := err.(*breadcrumbs); ok {
+		c := *e
+		// Clip the slice so the original is never modified by append
+		c.crumbs = append(e.crumbs[:len(e.crumbs):len(e.crumbs)], crumb)
+		return &c
+	}
+	return &breadcrumbs{wrapped: err, crumbs: []Breadcrumb{crumb}}
+}
+
+// Breadcrumbs returns all the breadcrumbs found in err's chain, in the order they were added
+func Breadcrumbs(err error) []Breadcrumb {
+	var result []Breadcrumb
+	for err != nil {
+		var crumbs []Breadcrumb
+		switch e := err.(type) {
+		case *breadcrumbs:
+			crumbs = e.crumbs
+		case *decodedError:
+			crumbs = e.env.Breadcrumbs
+		}
+		// Breadcrumbs closer to the cause were added first
+		if len(crumbs) != 0 {
+			result = append(append([]Breadcrumb{}, crumbs...), result...)
+		}
+		err = Unwrap(err)
+	}
+	return result
+}
+
+type breadcrumbs struct {
+	wrapped error
+	crumbs  []Breadcrumb
+}
+
+func (b *breadcrumbs) Unwrap() error {
+	return b.wrapped
+}
+
+func (b *breadcrumbs) ownFields() map[string]any { return nil }
+
+func (b *breadcrumbs) message() string { return NoMsg }
+
+func (b *breadcrumbs) clone() error {
+	c := *b
+	c.wrapped = Clone(b.wrapped)
+	return &c
+}
+
+func (b *breadcrumbs) stripStack() error {
+	c := *b
+	c.wrapped = StripStack(b.wrapped)
+	return &c
+}
+
+func (b *breadcrumbs) Is(target error) bool {
+	_, ok := target.(*breadcrumbs)
+	return ok && MatchWrapperType
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (b *breadcrumbs) Cause() error { return b.wrapped }
+
+func (b *breadcrumbs) Error() string {
+	return limitMsg(b.wrapped.Error())
+}
+
+func (b *breadcrumbs) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		b.writePlus(s)
+		return
+	}
+	_, _ = io.WriteString(s, MessageOf(b))
+}
+
+func (b *breadcrumbs) writePlus(w io.Writer) bool {
+	return formatPlus(w, NoMsg, b.wrapped, "")
+}
@@ -0,0 +1,61 @@
+package errors_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreadcrumbs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	errors.NowFunc = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	defer func() { errors.NowFunc = time.Now }()
+
+	err := errors.WithBreadcrumb(io.EOF, "connected")
+	err = errors.WithBreadcrumb(err, "fetched 200 rows")
+	first := err
+	err = errors.Wrap(err, "while syncing")
+	err = errors.WithBreadcrumb(err, "uploaded batch 1")
+
+	assert.Equal(t, "while syncing: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, []errors.Breadcrumb{
+		{Time: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC), Message: "connected"},
+		{Time: time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC), Message: "fetched 200 rows"},
+		{Time: time.Date(2024, 1, 1, 0, 0, 4, 0, time.UTC), Message: "uploaded batch 1"},
+	}, errors.Breadcrumbs(err))
+
+	// Adding a breadcrumb does not modify the original error
+	_ = errors.WithBreadcrumb(first, "retrying")
+	assert.Len(t, errors.Breadcrumbs(first), 2)
+
+	t.Run("ToJSON", func(t *testing.T) {
+		b, jErr := errors.ToJSON(err)
+		require.NoError(t, jErr)
+		assert.Contains(t, string(b), `"breadcrumbs":[{"time":"2024-01-01T00:00:01Z","message":"connected"}`)
+
+		env, jErr := errors.ParseJSON(b)
+		require.NoError(t, jErr)
+		assert.Equal(t, errors.Breadcrumbs(err), errors.Breadcrumbs(env.ToError()))
+	})
+
+	t.Run("Gob", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, gob.NewEncoder(&buf).Encode(gobReply{Err: err}))
+		var decoded gobReply
+		require.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+		assert.Equal(t, errors.Breadcrumbs(err), errors.Breadcrumbs(decoded.Err))
+	})
+
+	assert.Nil(t, errors.Breadcrumbs(io.EOF))
+	assert.NoError(t, errors.WithBreadcrumb(nil, "msg"))
+}
@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	buildInfoOnce   sync.Once
+	buildInfoFields map[string]any
+)
+
+// buildInfo returns the version, VCS revision and dirty flag of the main module, such
+// that stack trace line numbers can be matched to the commit which produced them.
+// Only the information available in the binary is included.
+func buildInfo() map[string]any {
+	buildInfoOnce.Do(func() {
+		buildInfoFields = make(map[string]any, 3)
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if info.Main.Version != "" {
+			buildInfoFields["excBuildVersion"] = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				buildInfoFields["excBuildRevision"] = s.Value
+			case "vcs.modified":
+				buildInfoFields["excBuildDirty"] = s.Value == "true"
+			}
+		}
+	})
+	return buildInfoFields
+}
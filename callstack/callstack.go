@@ -2,12 +2,15 @@ package callstack
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"path"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type FrameInfo struct {
@@ -17,6 +20,36 @@ type FrameInfo struct {
 	LineNo    int
 }
 
+// String returns the frame in the form `pkg.Func file.go:123`
+func (fi FrameInfo) String() string {
+	if fi.File == "" {
+		return fi.Func
+	}
+	return fmt.Sprintf("%s %s:%d", fi.Func, path.Base(fi.File), fi.LineNo)
+}
+
+// MarshalJSON encodes the frame as `{"func":"pkg.Func","file":"/path/to/file.go","line":123}`
+func (fi FrameInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Func string `json:"func"`
+		File string `json:"file"`
+		Line int    `json:"line"`
+	}{
+		Func: fi.Func,
+		File: fi.File,
+		Line: fi.LineNo,
+	})
+}
+
+// LogValue implements slog.LogValuer using the same keys as MarshalJSON()
+func (fi FrameInfo) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("func", fi.Func),
+		slog.String("file", fi.File),
+		slog.Int("line", fi.LineNo),
+	)
+}
+
 func GetCallStack(frames StackTrace) string {
 	var trace []string
 	for i := len(frames) - 1; i >= 0; i-- {
@@ -27,21 +60,94 @@ func GetCallStack(frames StackTrace) string {
 
 // GetLastFrame returns Caller information on the first frame in the stack trace.
 func GetLastFrame(frames StackTrace) FrameInfo {
-	if len(frames) == 0 {
+	return GetFrame(frames, 0)
+}
+
+// GetFirstFrame returns Caller information on the outermost (oldest) frame in the stack trace.
+func GetFirstFrame(frames StackTrace) FrameInfo {
+	return GetFrame(frames, len(frames)-1)
+}
+
+// GetFrame returns Caller information on the frame at index 'n' in the stack trace,
+// where 0 is the innermost (newest) frame. If 'n' is out of range an empty FrameInfo
+// is returned.
+func GetFrame(frames StackTrace, n int) FrameInfo {
+	if n < 0 || n >= len(frames) {
 		return FrameInfo{}
 	}
-	pc := uintptr(frames[0]) - 1
-	fn := runtime.FuncForPC(pc)
-	if fn == nil {
+	pc := frames[n].pc()
+	sym := resolve(pc)
+	if sym.name == "" {
 		return FrameInfo{Func: fmt.Sprintf("unknown func at %v", pc)}
 	}
-	filePath, lineNo := fn.FileLine(pc)
 	return FrameInfo{
 		CallStack: GetCallStack(frames),
-		Func:      FuncName(fn),
-		File:      filePath,
-		LineNo:    lineNo,
+		Func:      shortName(cleanSymbol(sym.name)),
+		File:      NormalizePath(sym.file),
+		LineNo:    sym.line,
+	}
+}
+
+// FramesAbove returns the portion of the stack trace beginning with the innermost
+// frame whose fully qualified function name starts with 'pkgPrefix'. Frames newer
+// than that frame are dropped. If no frame matches, nil is returned.
+//
+//	// Report the innermost frame inside our module
+//	caller := callstack.GetLastFrame(callstack.FramesAbove(trace, "github.com/mailgun/myservice"))
+func FramesAbove(frames StackTrace, pkgPrefix string) StackTrace {
+	for i, f := range frames {
+		if strings.HasPrefix(f.name(), pkgPrefix) {
+			return frames[i:]
+		}
 	}
+	return nil
+}
+
+// CleanGenericNames controls whether the type parameter suffix of generic functions
+// such as `[...]` or `[go.shape.string]` is removed from function names reported by
+// FuncName() and when formatting a Frame, such that `pkg.Map[...].func1` is reported
+// as `pkg.Map.func1`. It defaults to true.
+var CleanGenericNames = true
+
+// cleanSymbol removes all type parameter brackets from the function name
+func cleanSymbol(name string) string {
+	if !CleanGenericNames || !strings.Contains(name, "[") {
+		return name
+	}
+	var b strings.Builder
+	b.Grow(len(name))
+	var depth int
+	for _, r := range name {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// NormalizePaths controls whether file names reported by GetFrame() and when formatting
+// a Frame have their separators converted to forward slashes, such that binaries
+// built on Windows report the same file names as other platforms. It defaults to true.
+var NormalizePaths = true
+
+// FoldPathCase controls whether file names are converted to lower case, for use
+// with case-insensitive file systems. It defaults to false.
+var FoldPathCase = false
+
+// NormalizePath returns the file path normalized according to NormalizePaths and FoldPathCase
+func NormalizePath(filePath string) string {
+	if NormalizePaths {
+		filePath = strings.ReplaceAll(filePath, `\`, "/")
+	}
+	if FoldPathCase {
+		filePath = strings.ToLower(filePath)
+	}
+	return filePath
 }
 
 // FuncName given a runtime function spec returns a short function name in
@@ -51,7 +157,11 @@ func FuncName(fn *runtime.Func) string {
 	if fn == nil {
 		return ""
 	}
-	funcPath := fn.Name()
+	return shortName(cleanSymbol(fn.Name()))
+}
+
+// shortName removes the package path from a fully qualified function name
+func shortName(funcPath string) string {
 	idx := strings.LastIndex(funcPath, "/")
 	if idx == -1 {
 		return funcPath
@@ -63,7 +173,7 @@ func FuncName(fn *runtime.Func) string {
 type CallStack []uintptr
 
 func (cs *CallStack) Format(st fmt.State, verb rune) {
-	if verb == 'v' && st.Flag('+') {
+	if verb == 'v' && st.Flag('+') && cs != nil {
 		for _, pc := range *cs {
 			f := Frame(pc)
 			_, _ = fmt.Fprintf(st, "\n%+v", f)
@@ -72,6 +182,9 @@ func (cs *CallStack) Format(st fmt.State, verb rune) {
 }
 
 func (cs *CallStack) StackTrace() StackTrace {
+	if cs == nil {
+		return nil
+	}
 	f := make([]Frame, len(*cs))
 	for i := 0; i < len(f); i++ {
 		f[i] = Frame((*cs)[i])
@@ -79,14 +192,114 @@ func (cs *CallStack) StackTrace() StackTrace {
 	return f
 }
 
+// Trace is a CallStack captured by Capture() along with the number of frames which
+// were dropped from it because the stack was deeper than MaxDepth. The zero value
+// is an empty stack.
+type Trace struct {
+	*CallStack
+	Elided int
+}
+
+// ElidedFrames returns the number of frames which were dropped from
+// the stack because it was deeper than the capture limit.
+func (t Trace) ElidedFrames() int {
+	return t.Elided
+}
+
+// Format is identical to CallStack.Format() but ends `%+v` with the
+// number of frames elided, if any.
+func (t Trace) Format(st fmt.State, verb rune) {
+	t.CallStack.Format(st, verb)
+	if verb == 'v' && st.Flag('+') && t.Elided > 0 {
+		_, _ = fmt.Fprintf(st, "\n... %d more frames elided", t.Elided)
+	}
+}
+
+// CaptureStacks controls whether New() and NewCaller() capture the stack. When false
+// they return an empty CallStack, such that errors report no stack trace. It defaults to true.
+// Stack capture can also be removed at compile time using the errors_nostack build tag.
+var CaptureStacks = true
+
+// emptyStack is returned when stack capture is disabled, it is shared as
+// a CallStack cannot be modified once created.
+var emptyStack = &CallStack{}
+
+// defaultMaxDepth is the default value of MaxDepth, and the size of the buffer
+// used by New() which avoids an extra allocation for stacks of the default depth.
+const defaultMaxDepth = 32
+
+// MaxDepth is the maximum number of frames captured by New(). It defaults to 32.
+var MaxDepth = defaultMaxDepth
+
 // New creates a new CallStack struct from current stack minus 'skip' number of frames.
+// Use Capture() to also learn how many frames were dropped because of MaxDepth.
 func New(skip int) *CallStack {
+	return Capture(skip + 1).CallStack
+}
+
+// Capture is identical to New() but returns the stack along with the number
+// of frames which were dropped from it because of MaxDepth.
+func Capture(skip int) Trace {
+	if !captureCompiled || !CaptureStacks {
+		return Trace{CallStack: emptyStack}
+	}
 	skip += 2
-	const depth = 32
-	var pcs [depth]uintptr
-	n := runtime.Callers(skip, pcs[:])
-	var st CallStack = pcs[0:n]
-	return &st
+	depth := MaxDepth
+	if depth <= 0 {
+		depth = defaultMaxDepth
+	}
+	buf := pcPool.Get().(*[]uintptr)
+	if cap(*buf) < depth {
+		*buf = make([]uintptr, depth)
+	}
+	pcs := (*buf)[:depth]
+	n := runtime.Callers(skip, pcs)
+
+	// Only the frames captured are retained, the buffer is returned to the pool
+	cs := CallStack(append(make([]uintptr, 0, n), pcs[:n]...))
+	pcPool.Put(buf)
+	t := Trace{CallStack: &cs}
+	if n == depth {
+		t.Elided = countFrames(skip, depth) - depth
+	}
+	return t
+}
+
+// pcPool holds the buffers New() captures program counters into, such that
+// only the frames retained by the CallStack are allocated.
+var pcPool = sync.Pool{
+	New: func() any {
+		buf := make([]uintptr, defaultMaxDepth)
+		return &buf
+	},
+}
+
+// NewCaller creates a new CallStack containing only the frame of the caller minus
+// 'skip' number of frames. It is much cheaper than New() and is intended for hot
+// paths which only need to report where an error occurred.
+func NewCaller(skip int) *CallStack {
+	if !captureCompiled || !CaptureStacks {
+		return emptyStack
+	}
+	pcs := make([]uintptr, 1)
+	n := runtime.Callers(skip+2, pcs)
+	cs := CallStack(pcs[:n])
+	return &cs
+}
+
+// countFrames returns the total number of frames on the stack minus 'skip'.
+// It is only called when the stack has been truncated, so the cost of
+// growing the buffer is only paid by deep stacks.
+func countFrames(skip, depth int) int {
+	buf := make([]uintptr, depth*2)
+	for {
+		// +1 to account for the call to countFrames()
+		n := runtime.Callers(skip+1, buf)
+		if n < len(buf) {
+			return n
+		}
+		buf = make([]uintptr, len(buf)*2)
+	}
 }
 
 // GoRoutineID returns the current goroutine id.
@@ -111,32 +324,35 @@ func (f Frame) pc() uintptr { return uintptr(f) - 1 }
 // file returns the full path to the file that contains the
 // function for this Frame's pc.
 func (f Frame) file() string {
-	fn := runtime.FuncForPC(f.pc())
-	if fn == nil {
+	sym := resolve(f.pc())
+	if sym.name == "" {
 		return "unknown"
 	}
-	file, _ := fn.FileLine(f.pc())
-	return file
+	return NormalizePath(sym.file)
 }
 
 // line returns the line number of source code of the
 // function for this Frame's pc.
 func (f Frame) line() int {
-	fn := runtime.FuncForPC(f.pc())
-	if fn == nil {
-		return 0
-	}
-	_, line := fn.FileLine(f.pc())
-	return line
+	return resolve(f.pc()).line
 }
 
 // name returns the name of this function, if known.
 func (f Frame) name() string {
-	fn := runtime.FuncForPC(f.pc())
-	if fn == nil {
+	sym := resolve(f.pc())
+	if sym.name == "" {
 		return "unknown"
 	}
-	return fn.Name()
+	return cleanSymbol(sym.name)
+}
+
+// funcName returns the short function name as reported by FuncName(), if known
+func (f Frame) funcName() string {
+	sym := resolve(f.pc())
+	if sym.name == "" {
+		return ""
+	}
+	return shortName(cleanSymbol(sym.name))
 }
 
 // Format formats the frame according to the fmt.Formatter interface.
@@ -187,6 +403,12 @@ type HasStackTrace interface {
 	StackTrace() StackTrace
 }
 
+// HasElidedFrames is implemented by errors which can report how many
+// frames were dropped when they captured their own stack trace.
+type HasElidedFrames interface {
+	ElidedFrames() int
+}
+
 // StackTrace is stack of Frames from innermost (newest) to outermost (oldest).
 type StackTrace []Frame
 
@@ -217,6 +439,55 @@ func (st StackTrace) Format(s fmt.State, verb rune) {
 	}
 }
 
+// FrameRecord is a machine-readable representation of a single Frame
+type FrameRecord struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Records returns each Frame in the stack as a FrameRecord, such that systems which
+// group or symbolicate stack traces do not need to parse the formatted output.
+func (st StackTrace) Records() []FrameRecord {
+	records := make([]FrameRecord, len(st))
+	for i, f := range st {
+		records[i] = FrameRecord{
+			Func: f.funcName(),
+			File: f.file(),
+			Line: f.line(),
+		}
+	}
+	return records
+}
+
+// RuntimeFrames returns the stack as the frames reported by the runtime package, for use
+// with code which already processes runtime.Frame. Unlike the Frames of the StackTrace,
+// calls which were inlined by the compiler are reported as frames of their own.
+func (st StackTrace) RuntimeFrames() []runtime.Frame {
+	if len(st) == 0 {
+		return nil
+	}
+	frames := st.CallersFrames()
+	result := make([]runtime.Frame, 0, len(st))
+	for {
+		frame, more := frames.Next()
+		result = append(result, frame)
+		if !more {
+			return result
+		}
+	}
+}
+
+// CallersFrames returns an iterator over the stack, identical to the one returned by
+// runtime.CallersFrames() for the program counters the stack was captured from.
+func (st StackTrace) CallersFrames() *runtime.Frames {
+	pcs := make([]uintptr, len(st))
+	for i, f := range st {
+		pcs[i] = uintptr(f)
+	}
+	return runtime.CallersFrames(pcs)
+}
+
 // formatSlice will format this StackTrace into the given buffer as a slice of
 // Frame, only valid when called with '%s' or '%v'.
 func (st StackTrace) formatSlice(s fmt.State, verb rune) {
@@ -0,0 +1,293 @@
+package callstack_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/mailgun/errors/callstack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFrame(t *testing.T) {
+	trace := callstack.New(0).StackTrace()
+	require.NotEmpty(t, trace)
+
+	assert.Equal(t, "callstack_test.TestGetFrame", callstack.GetLastFrame(trace).Func)
+	assert.Equal(t, callstack.GetLastFrame(trace), callstack.GetFrame(trace, 0))
+	assert.Equal(t, "testing.tRunner", callstack.GetFrame(trace, 1).Func)
+	assert.Equal(t, callstack.GetFrame(trace, len(trace)-1), callstack.GetFirstFrame(trace))
+
+	assert.Equal(t, callstack.FrameInfo{}, callstack.GetFrame(trace, -1))
+	assert.Equal(t, callstack.FrameInfo{}, callstack.GetFrame(trace, len(trace)))
+	assert.Equal(t, callstack.FrameInfo{}, callstack.GetFirstFrame(nil))
+}
+
+func TestFramesAbove(t *testing.T) {
+	trace := callstack.New(0).StackTrace()
+
+	above := callstack.FramesAbove(trace, "testing.")
+	require.Len(t, above, len(trace)-1)
+	assert.Equal(t, "testing.tRunner", callstack.GetLastFrame(above).Func)
+
+	assert.Equal(t, trace, callstack.FramesAbove(trace, "github.com/mailgun/errors/callstack_test"))
+	assert.Nil(t, callstack.FramesAbove(trace, "github.com/does/not/exist"))
+}
+
+func TestFrameInfo(t *testing.T) {
+	fi := callstack.FrameInfo{
+		Func:   "errors_test.TestFrameInfo",
+		File:   "/path/to/callstack_test.go",
+		LineNo: 42,
+	}
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "errors_test.TestFrameInfo callstack_test.go:42", fi.String())
+		assert.Equal(t, "errors_test.TestFrameInfo callstack_test.go:42", fmt.Sprintf("%s", fi))
+		assert.Equal(t, "", callstack.FrameInfo{}.String())
+	})
+
+	t.Run("MarshalJSON", func(t *testing.T) {
+		b, err := json.Marshal(fi)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"func":"errors_test.TestFrameInfo","file":"/path/to/callstack_test.go","line":42}`, string(b))
+	})
+
+	t.Run("LogValue", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := slog.New(slog.NewTextHandler(&buf, nil))
+		log.Info("test", "caller", fi)
+		assert.Contains(t, buf.String(),
+			"caller.func=errors_test.TestFrameInfo caller.file=/path/to/callstack_test.go caller.line=42")
+	})
+}
+
+func captureTwice() (callstack.StackTrace, callstack.StackTrace) {
+	a := callstack.New(0).StackTrace()
+	b := callstack.New(0).StackTrace()
+	return a, b
+}
+
+func TestEqual(t *testing.T) {
+	a, b := captureTwice()
+
+	assert.True(t, callstack.Equal(a, a))
+	assert.False(t, callstack.Equal(a, b))
+	assert.True(t, callstack.Equal(a, b, callstack.IgnoreLineNumbers()))
+	assert.False(t, callstack.Equal(a, b[1:], callstack.IgnoreLineNumbers()))
+}
+
+func TestDiffFrames(t *testing.T) {
+	a, b := captureTwice()
+
+	assert.Nil(t, callstack.DiffFrames(a, a))
+	assert.Nil(t, callstack.DiffFrames(a, b, callstack.IgnoreLineNumbers()))
+
+	diff := callstack.DiffFrames(a, b)
+	require.Len(t, diff, 1)
+	assert.Regexp(t, `^frame 0: .*callstack_test.captureTwice .*callstack_test.go:\d+ != .*callstack_test.captureTwice .*callstack_test.go:\d+$`, diff[0])
+
+	diff = callstack.DiffFrames(a, a[:len(a)-1])
+	require.Len(t, diff, 1)
+	assert.Regexp(t, `^frame \d+: .* != <missing>$`, diff[0])
+}
+
+func TestNewCaller(t *testing.T) {
+	trace := callstack.NewCaller(0).StackTrace()
+	require.Len(t, trace, 1)
+	assert.Equal(t, "callstack_test.TestNewCaller", callstack.GetLastFrame(trace).Func)
+}
+
+type generic[V any] struct{}
+
+func (g *generic[V]) method() callstack.StackTrace {
+	return callstack.New(0).StackTrace()
+}
+
+func genericFunc[V any](v V) callstack.StackTrace {
+	f := func() callstack.StackTrace { return callstack.New(0).StackTrace() }
+	return f()
+}
+
+func TestCaptureStacks(t *testing.T) {
+	callstack.CaptureStacks = false
+	defer func() { callstack.CaptureStacks = true }()
+
+	assert.Empty(t, callstack.New(0).StackTrace())
+	assert.Empty(t, callstack.NewCaller(0).StackTrace())
+	assert.Equal(t, "", fmt.Sprintf("%+v", callstack.New(0)))
+}
+
+func TestMaxDepth(t *testing.T) {
+	defer func() { callstack.MaxDepth = 32 }()
+
+	callstack.MaxDepth = 1
+	trace := callstack.Capture(0)
+	require.Len(t, trace.StackTrace(), 1)
+	assert.Equal(t, "callstack_test.TestMaxDepth", callstack.GetLastFrame(trace.StackTrace()).Func)
+	assert.Greater(t, trace.ElidedFrames(), 0)
+	assert.Regexp(t, `\.\.\. \d+ more frames elided$`, fmt.Sprintf("%+v", trace))
+
+	// The count is kept with the stack, such that copies report it too
+	cp := trace
+	assert.Equal(t, trace.ElidedFrames(), cp.ElidedFrames())
+
+	// CallStack remains a slice of program counters
+	cs := callstack.New(0)
+	pcs := []uintptr(*cs)
+	assert.Len(t, pcs, 1)
+	assert.Equal(t, "callstack_test.TestMaxDepth", callstack.GetLastFrame(cs.StackTrace()).Func)
+
+	callstack.MaxDepth = 64
+	assert.Equal(t, 0, callstack.Capture(0).ElidedFrames())
+	assert.Empty(t, callstack.Trace{}.StackTrace())
+}
+
+func TestCleanGenericNames(t *testing.T) {
+	trace := genericFunc("string")
+	assert.Equal(t, "callstack_test.genericFunc.func1", callstack.GetLastFrame(trace).Func)
+	assert.Equal(t, "callstack_test.genericFunc", callstack.GetFrame(trace, 1).Func)
+	assert.Equal(t, "genericFunc.func1", fmt.Sprintf("%n", trace[0]))
+	assert.Contains(t, fmt.Sprintf("%+v", trace[0]), "github.com/mailgun/errors/callstack_test.genericFunc.func1\n")
+
+	trace = (&generic[int]{}).method()
+	assert.Equal(t, "callstack_test.(*generic).method", callstack.GetLastFrame(trace).Func)
+
+	t.Run("disabled", func(t *testing.T) {
+		callstack.CleanGenericNames = false
+		defer func() { callstack.CleanGenericNames = true }()
+
+		trace := genericFunc(1)
+		assert.Equal(t, "callstack_test.genericFunc[...].func1", callstack.GetLastFrame(trace).Func)
+	})
+}
+
+func TestNormalizePath(t *testing.T) {
+	assert.Equal(t, "C:/Users/Build/src/errors/wrap.go", callstack.NormalizePath(`C:\Users\Build\src\errors\wrap.go`))
+	assert.Equal(t, "/home/build/errors/wrap.go", callstack.NormalizePath("/home/build/errors/wrap.go"))
+
+	t.Run("FoldPathCase", func(t *testing.T) {
+		callstack.FoldPathCase = true
+		defer func() { callstack.FoldPathCase = false }()
+		assert.Equal(t, "c:/users/build/src/errors/wrap.go", callstack.NormalizePath(`C:\Users\Build\src\errors\wrap.go`))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		callstack.NormalizePaths = false
+		defer func() { callstack.NormalizePaths = true }()
+		assert.Equal(t, `C:\Users\wrap.go`, callstack.NormalizePath(`C:\Users\wrap.go`))
+	})
+}
+
+func TestStackTraceRecords(t *testing.T) {
+	trace := callstack.New(0).StackTrace()
+	records := trace.Records()
+	require.Len(t, records, len(trace))
+
+	caller := callstack.GetLastFrame(trace)
+	assert.Equal(t, callstack.FrameRecord{Func: caller.Func, File: caller.File, Line: caller.LineNo}, records[0])
+
+	b, err := json.Marshal(records[:1])
+	require.NoError(t, err)
+	assert.Regexp(t, `^\[{"func":"callstack_test.TestStackTraceRecords","file":".*/callstack_test.go","line":\d+}\]$`, string(b))
+}
+
+func TestResolver(t *testing.T) {
+	cs := callstack.New(0)
+	raw := cs.Raw()
+	require.NotEmpty(t, raw.BuildID)
+	assert.Equal(t, callstack.BuildID(), raw.BuildID)
+
+	// Runtime frames are found before the anchor in the binary
+	assert.Less(t, raw.Offsets[len(raw.Offsets)-1], int64(0))
+
+	// The raw stack survives a round trip through JSON
+	b, err := json.Marshal(raw)
+	require.NoError(t, err)
+	var decoded callstack.RawStack
+	require.NoError(t, json.Unmarshal(b, &decoded))
+
+	path, err := os.Executable()
+	require.NoError(t, err)
+	r, err := callstack.NewResolver(path)
+	require.NoError(t, err)
+
+	records, err := r.Resolve(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, cs.StackTrace().Records(), records)
+
+	decoded.BuildID = "mismatch"
+	_, err = r.Resolve(decoded)
+	assert.ErrorContains(t, err, "does not match binary build id")
+}
+
+func TestSameFunc(t *testing.T) {
+	a, b := captureTwice()
+	first, second := callstack.GetLastFrame(a), callstack.GetLastFrame(b)
+
+	assert.NotEqual(t, first.LineNo, second.LineNo)
+	assert.True(t, callstack.SameFunc(first, second))
+	assert.False(t, callstack.SameFunc(first, callstack.GetLastFrame(callstack.New(0).StackTrace())))
+
+	assert.True(t, callstack.SameFuncs(a, b))
+	assert.False(t, callstack.SameFuncs(a, b[1:]))
+}
+
+func TestNewPooledBuffers(t *testing.T) {
+	// Stacks captured after the buffer is reused must not share frames
+	first := callstack.New(0).StackTrace()
+	second := captureFromHelper()
+	assert.Equal(t, "callstack_test.TestNewPooledBuffers", callstack.GetLastFrame(first).Func)
+	assert.Equal(t, "callstack_test.captureFromHelper", callstack.GetLastFrame(second).Func)
+}
+
+func captureFromHelper() callstack.StackTrace {
+	return callstack.New(0).StackTrace()
+}
+
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = callstack.New(0)
+	}
+}
+
+func TestSymbolCache(t *testing.T) {
+	defer func(size int) { callstack.SymbolCacheSize = size }(callstack.SymbolCacheSize)
+
+	for _, size := range []int{0, 1, 4096} {
+		callstack.SymbolCacheSize = size
+		trace := captureFromHelper()
+		// Resolving the same frames twice must give identical results with or without the cache
+		first, second := callstack.GetLastFrame(trace), callstack.GetLastFrame(trace)
+		assert.Equal(t, first, second)
+		assert.Equal(t, "callstack_test.captureFromHelper", first.Func)
+		assert.Equal(t, fmt.Sprintf("%+v", trace), fmt.Sprintf("%+v", trace))
+	}
+}
+
+func BenchmarkGetLastFrame(b *testing.B) {
+	trace := callstack.New(0).StackTrace()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = callstack.GetLastFrame(trace)
+	}
+}
+
+func TestRuntimeFrames(t *testing.T) {
+	trace := captureFromHelper()
+	frames := trace.RuntimeFrames()
+	require.GreaterOrEqual(t, len(frames), len(trace))
+	assert.Equal(t, "github.com/mailgun/errors/callstack_test.captureFromHelper", frames[0].Function)
+	assert.Equal(t, callstack.GetLastFrame(trace).LineNo, frames[0].Line)
+
+	iter := trace.CallersFrames()
+	first, _ := iter.Next()
+	assert.Equal(t, frames[0], first)
+
+	assert.Empty(t, callstack.StackTrace(nil).RuntimeFrames())
+}
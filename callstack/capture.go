@@ -0,0 +1,6 @@
+//go:build !errors_nostack
+
+package callstack
+
+// captureCompiled is false when built with the errors_nostack tag
+const captureCompiled = true
@@ -0,0 +1,11 @@
+//go:build errors_nostack
+
+package callstack
+
+// captureCompiled is false when built with the errors_nostack tag, in which case
+// New() and NewCaller() compile to returning an empty CallStack and errors report
+// no stack trace. This gives latency critical binaries a zero cost opt-out while
+// keeping the API unchanged.
+//
+//	go build -tags errors_nostack ./...
+const captureCompiled = false
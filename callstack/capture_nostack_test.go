@@ -0,0 +1,16 @@
+//go:build errors_nostack
+
+package callstack_test
+
+import (
+	"testing"
+
+	"github.com/mailgun/errors/callstack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoStackBuild(t *testing.T) {
+	assert.Empty(t, callstack.New(0).StackTrace())
+	assert.Empty(t, callstack.NewCaller(0).StackTrace())
+	assert.Equal(t, 0, callstack.Capture(0).ElidedFrames())
+}
@@ -0,0 +1,77 @@
+package callstack
+
+import (
+	"fmt"
+)
+
+// CompareOption modifies how Equal() and DiffFrames() compare frames
+type CompareOption func(*compareOptions)
+
+type compareOptions struct {
+	ignoreLines bool
+}
+
+// IgnoreLineNumbers compares frames by function name and file only, such that
+// tests do not break when unrelated lines are added or removed.
+func IgnoreLineNumbers() CompareOption {
+	return func(o *compareOptions) {
+		o.ignoreLines = true
+	}
+}
+
+// Equal returns true if both stack traces contain the same frames in the same order.
+//
+//	assert.True(t, callstack.Equal(expected, actual, callstack.IgnoreLineNumbers()))
+func Equal(a, b StackTrace, opts ...CompareOption) bool {
+	return len(DiffFrames(a, b, opts...)) == 0
+}
+
+// DiffFrames returns a human-readable description of each frame which differs
+// between the two stack traces. If the stack traces are equal, nil is returned.
+func DiffFrames(a, b StackTrace, opts ...CompareOption) []string {
+	var o compareOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var diff []string
+	for i := 0; i < len(a) || i < len(b); i++ {
+		left, right := "<missing>", "<missing>"
+		if i < len(a) {
+			left = o.frameString(a[i])
+		}
+		if i < len(b) {
+			right = o.frameString(b[i])
+		}
+		if left != right {
+			diff = append(diff, fmt.Sprintf("frame %d: %s != %s", i, left, right))
+		}
+	}
+	return diff
+}
+
+func (o compareOptions) frameString(f Frame) string {
+	if o.ignoreLines {
+		return f.name() + " " + f.file()
+	}
+	return fmt.Sprintf("%s %s:%d", f.name(), f.file(), f.line())
+}
+
+// SameFunc returns true if both frames are in the same function of the same file,
+// regardless of the line within the function. This allows errors to be matched or
+// deduplicated by where they occurred without refactors inside the function causing
+// a mismatch.
+//
+//	if callstack.SameFunc(stored, current) {
+//		...
+//	}
+func SameFunc(a, b FrameInfo) bool {
+	return a.Func == b.Func && a.File == b.File
+}
+
+// SameFuncs returns true if both stack traces contain the same functions of the same
+// files in the same order, regardless of the line within each function. It is
+// equivalent to Equal() with IgnoreLineNumbers().
+func SameFuncs(a, b StackTrace) bool {
+	return Equal(a, b, IgnoreLineNumbers())
+}
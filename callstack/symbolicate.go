@@ -0,0 +1,158 @@
+package callstack
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// RawStack is an unresolved stack trace which can be serialized cheaply and
+// symbolicated later using a Resolver with a copy of the binary which produced it.
+// Program counters are stored as signed offsets from a known function, such that
+// they remain valid when the binary is loaded at a different address. Frames found
+// before the function in the binary, such as runtime frames, have negative offsets.
+type RawStack struct {
+	BuildID string  `json:"build_id"`
+	Offsets []int64 `json:"offsets"`
+}
+
+// anchorPC is the function program counters in a RawStack are relative to
+var anchorPC = reflect.ValueOf(New).Pointer()
+
+const anchorSymbol = "github.com/mailgun/errors/callstack.New"
+
+// Raw returns the stack as a RawStack without resolving any symbols
+func (cs *CallStack) Raw() RawStack {
+	return cs.StackTrace().Raw()
+}
+
+// Raw returns the stack as a RawStack without resolving any symbols
+func (st StackTrace) Raw() RawStack {
+	raw := RawStack{
+		BuildID: BuildID(),
+		Offsets: make([]int64, len(st)),
+	}
+	for i, f := range st {
+		raw.Offsets[i] = int64(f) - int64(anchorPC)
+	}
+	return raw
+}
+
+var (
+	buildIDOnce sync.Once
+	buildID     string
+)
+
+// BuildID returns the Go build ID of the running binary, or an empty string
+// if it could not be determined.
+func BuildID() string {
+	buildIDOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+		f, err := elf.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		buildID, _ = readBuildID(f)
+	})
+	return buildID
+}
+
+// readBuildID reads the Go build ID from the `.note.go.buildid` section of an ELF binary
+func readBuildID(f *elf.File) (string, error) {
+	section := f.Section(".note.go.buildid")
+	if section == nil {
+		return "", errors.New("binary has no '.note.go.buildid' section")
+	}
+	data, err := section.Data()
+	if err != nil {
+		return "", fmt.Errorf("while reading build id: %w", err)
+	}
+	// A note is the name size, description size and type followed by the 4 byte
+	// aligned name `Go\x00\x00` and the description which is the build id.
+	if len(data) < 16 {
+		return "", errors.New("malformed build id note")
+	}
+	nameSize := f.ByteOrder.Uint32(data[0:4])
+	descSize := f.ByteOrder.Uint32(data[4:8])
+	start := 12 + (nameSize+3)&^3
+	if uint32(len(data)) < start+descSize || !bytes.HasPrefix(data[12:], []byte("Go\x00")) {
+		return "", errors.New("malformed build id note")
+	}
+	return string(data[start : start+descSize]), nil
+}
+
+// Resolver symbolicates RawStack values using the binary which produced them.
+// Only ELF binaries are supported.
+type Resolver struct {
+	buildID string
+	anchor  uint64
+	table   *gosym.Table
+}
+
+// NewResolver opens the binary found at path and prepares it for symbolication
+func NewResolver(path string) (*Resolver, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("while opening binary: %w", err)
+	}
+	defer f.Close()
+
+	id, err := readBuildID(f)
+	if err != nil {
+		return nil, err
+	}
+
+	text := f.Section(".text")
+	pcln := f.Section(".gopclntab")
+	if text == nil || pcln == nil {
+		return nil, errors.New("binary has no '.text' or '.gopclntab' section")
+	}
+	data, err := pcln.Data()
+	if err != nil {
+		return nil, fmt.Errorf("while reading '.gopclntab': %w", err)
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return nil, fmt.Errorf("while parsing '.gopclntab': %w", err)
+	}
+
+	fn := table.LookupFunc(anchorSymbol)
+	if fn == nil {
+		return nil, fmt.Errorf("binary does not contain '%s'", anchorSymbol)
+	}
+	return &Resolver{buildID: id, anchor: fn.Entry, table: table}, nil
+}
+
+// Resolve returns the frames of the raw stack. It returns an error if the raw stack
+// was not produced by the binary the Resolver was created with.
+func (r *Resolver) Resolve(raw RawStack) ([]FrameRecord, error) {
+	if raw.BuildID != r.buildID {
+		return nil, fmt.Errorf("build id '%s' does not match binary build id '%s'", raw.BuildID, r.buildID)
+	}
+	records := make([]FrameRecord, len(raw.Offsets))
+	for i, offset := range raw.Offsets {
+		// Like Frame, the program counter is the return address; subtract
+		// one to find the line of the call instruction.
+		pc := uint64(int64(r.anchor)+offset) - 1
+		file, line, fn := r.table.PCToLine(pc)
+		if fn == nil {
+			records[i] = FrameRecord{Func: fmt.Sprintf("unknown func at %#x", pc)}
+			continue
+		}
+		records[i] = FrameRecord{
+			Func: shortName(cleanSymbol(fn.Name)),
+			File: NormalizePath(file),
+			Line: line,
+		}
+	}
+	return records, nil
+}
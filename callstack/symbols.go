@@ -0,0 +1,80 @@
+package callstack
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+)
+
+// SymbolCacheSize is the number of program counters whose function name, file and line
+// are cached, such that frames of hot wrap sites are resolved once instead of on every
+// call to ToMap() or Format(). The least recently used entries are evicted first. Zero
+// disables the cache. It should only be modified once at startup.
+var SymbolCacheSize = 4096
+
+// symbol is the unmodified result of resolving a program counter, the options such
+// as CleanGenericNames are applied when the symbol is used, as they may change.
+type symbol struct {
+	// name is the fully qualified function name, empty if the function is unknown
+	name string
+	file string
+	line int
+}
+
+// resolve returns the symbol for the program counter, using the cache if possible
+func resolve(pc uintptr) symbol {
+	if s, ok := symbols.get(pc); ok {
+		return s
+	}
+	var s symbol
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		s.name = fn.Name()
+		s.file, s.line = fn.FileLine(pc)
+	}
+	symbols.put(pc, s)
+	return s
+}
+
+var symbols = symbolCache{entries: map[uintptr]*list.Element{}, order: list.New()}
+
+// symbolCache is an LRU cache of symbols keyed by program counter
+type symbolCache struct {
+	mu      sync.Mutex
+	entries map[uintptr]*list.Element
+	order   *list.List
+}
+
+type symbolEntry struct {
+	pc  uintptr
+	sym symbol
+}
+
+func (c *symbolCache) get(pc uintptr) (symbol, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[pc]
+	if !ok {
+		return symbol{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*symbolEntry).sym, true
+}
+
+func (c *symbolCache) put(pc uintptr, s symbol) {
+	size := SymbolCacheSize
+	if size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[pc]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[pc] = c.order.PushFront(&symbolEntry{pc: pc, sym: s})
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*symbolEntry).pc)
+	}
+}
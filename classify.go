@@ -0,0 +1,58 @@
+package errors
+
+import (
+	"sync"
+)
+
+// Classifier inspects an error and returns the Kind and any fields which describe it.
+// It returns false if the error is not one it knows how to classify.
+//
+//	errors.RegisterClassifier(func(err error) (errors.Kind, errors.Fields, bool) {
+//		var netErr net.Error
+//		if errors.As(err, &netErr) && netErr.Timeout() {
+//			return "timeout", nil, true
+//		}
+//		return "", nil, false
+//	})
+type Classifier func(err error) (Kind, Fields, bool)
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   []*Classifier
+)
+
+// RegisterClassifier adds a classifier used by Classify(). Classifiers are run in
+// the order they were registered. It is safe to call from init() in multiple packages.
+// RegisterClassifier returns a function which removes the classifier.
+func RegisterClassifier(c Classifier) (remove func()) {
+	entry := &c
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	classifiers = append(classifiers, entry)
+	return func() {
+		classifiersMu.Lock()
+		defer classifiersMu.Unlock()
+		classifiers = without(classifiers, entry)
+	}
+}
+
+// Classify runs the registered classifiers against err and returns err annotated with
+// the Kind and fields of the first classifier which recognizes it. If no classifier
+// recognizes the error, err is returned unchanged.
+// If err is nil, Classify returns nil.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	classifiersMu.RLock()
+	defer classifiersMu.RUnlock()
+
+	for _, c := range classifiers {
+		kind, f, ok := (*c)(err)
+		if !ok {
+			continue
+		}
+		return WrapOpts(err, NoMsg, WithKind(kind), WithFieldsOpt(f), NoStack())
+	}
+	return err
+}
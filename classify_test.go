@@ -0,0 +1,48 @@
+package errors_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	removeTimeout := errors.RegisterClassifier(func(err error) (errors.Kind, errors.Fields, bool) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "timeout", errors.Fields{"retryable": true}, true
+		}
+		return "", nil, false
+	})
+	defer removeTimeout()
+	removeCanceled := errors.RegisterClassifier(func(err error) (errors.Kind, errors.Fields, bool) {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return "canceled", nil, true
+		}
+		return "", nil, false
+	})
+	defer removeCanceled()
+
+	err := errors.Classify(errors.Wrap(context.DeadlineExceeded, "while querying"))
+	assert.Equal(t, "while querying: context deadline exceeded", err.Error())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	assert.Equal(t, errors.Kind("timeout"), errors.KindOf(err))
+	m := errors.ToMap(err)
+	assert.Equal(t, true, m["retryable"])
+	assert.Equal(t, "errors_test.TestClassify", m["excFuncName"])
+
+	err = errors.Classify(context.Canceled)
+	assert.Equal(t, errors.Kind("canceled"), errors.KindOf(err))
+
+	assert.Equal(t, io.EOF, errors.Classify(io.EOF))
+	assert.Nil(t, errors.Classify(nil))
+
+	// Once removed, the next classifier which recognizes the error is used
+	removeTimeout()
+	assert.Equal(t, errors.Kind("canceled"), errors.KindOf(errors.Classify(context.DeadlineExceeded)))
+	removeCanceled()
+	assert.Equal(t, context.DeadlineExceeded, errors.Classify(context.DeadlineExceeded))
+}
@@ -0,0 +1,137 @@
+// Command errfmt pretty-prints errors serialized by errors.ToJSON() or logged using
+// errors.ToMap(), such that on-call engineers can inspect errors pulled from log storage.
+//
+//	kubectl logs deploy/api | errfmt
+//	errfmt < error.json
+//
+// Each line of the input is inspected for a JSON document, lines which do not contain
+// one are ignored. Color is disabled with -no-color or by setting NO_COLOR.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mailgun/errors"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorCyan  = "\x1b[36m"
+	colorDim   = "\x1b[2m"
+)
+
+func main() {
+	noColor := flag.Bool("no-color", os.Getenv("NO_COLOR") != "", "disable color output")
+	flag.Parse()
+
+	if err := run(os.Stdin, os.Stdout, !*noColor); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "errfmt: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run pretty-prints every error found in r
+func run(r io.Reader, w io.Writer, color bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		env, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		p := printer{w: w, color: color}
+		p.print(env)
+	}
+	return scanner.Err()
+}
+
+// parseLine returns the envelope from a line which is either a JSON document, or
+// a log line with a JSON document after a prefix such as a timestamp.
+func parseLine(line string) (*errors.Envelope, bool) {
+	idx := strings.IndexByte(line, '{')
+	if idx == -1 {
+		return nil, false
+	}
+	env, err := errors.ParseJSON([]byte(line[idx:]))
+	if err != nil || env.Message == "" {
+		return nil, false
+	}
+	return env, true
+}
+
+type printer struct {
+	w     io.Writer
+	color bool
+}
+
+func (p printer) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func (p printer) printf(format string, a ...any) {
+	_, _ = fmt.Fprintf(p.w, format, a...)
+}
+
+func (p printer) print(env *errors.Envelope) {
+	p.printf("%s %s\n", p.paint(colorBold+colorRed, "error:"), p.paint(colorBold, env.Message))
+	if env.Type != "" {
+		p.printf("  %s %s\n", p.paint(colorCyan, "type:"), env.Type)
+	}
+	for _, attr := range []struct{ name, value string }{
+		{"code:", env.Code},
+		{"kind:", string(env.Kind)},
+		{"id:", env.ID},
+	} {
+		if attr.value != "" {
+			p.printf("  %s %s\n", p.paint(colorCyan, attr.name), attr.value)
+		}
+	}
+	if env.Status != 0 {
+		p.printf("  %s %d\n", p.paint(colorCyan, "status:"), env.Status)
+	}
+
+	if len(env.Messages) > 1 {
+		p.printf("  %s\n", p.paint(colorCyan, "chain:"))
+		for i, msg := range env.Messages {
+			p.printf("    %s%s\n", strings.Repeat("  ", i), msg)
+		}
+	}
+
+	if len(env.Fields) != 0 {
+		keys := make([]string, 0, len(env.Fields))
+		for key := range env.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		p.printf("  %s\n", p.paint(colorCyan, "fields:"))
+		for _, key := range keys {
+			p.printf("    %s = %v\n", key, env.Fields[key])
+		}
+	}
+
+	if len(env.Breadcrumbs) != 0 {
+		p.printf("  %s\n", p.paint(colorCyan, "breadcrumbs:"))
+		for _, b := range env.Breadcrumbs {
+			p.printf("    %s %s\n", p.paint(colorDim, b.Time.Format("15:04:05.000")), b.Message)
+		}
+	}
+
+	if len(env.Frames) != 0 {
+		p.printf("  %s\n", p.paint(colorCyan, "stack:"))
+		for _, f := range env.Frames {
+			p.printf("    %s\n      %s\n", f.Func, p.paint(colorDim, fmt.Sprintf("%s:%d", f.File, f.Line)))
+		}
+	}
+	p.printf("\n")
+}
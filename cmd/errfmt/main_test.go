@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	err := errors.WrapOpts(errors.Wrap(io.EOF, "while reading"), "while fetching",
+		errors.WithCode("fetch.failed"),
+		errors.WithFieldsOpt(errors.Fields{"domain": "example.com", "attempt": 2}),
+	)
+	b, jErr := errors.ToJSON(err)
+	require.NoError(t, jErr)
+
+	input := strings.Join([]string{
+		"not an error",
+		string(b),
+		`2024-01-01T00:00:00Z ERROR {"excValue":"legacy: EOF","excType":"*errors.wrappedError","excFuncName":"main.run","excFileName":"main.go","excLineNum":10,"user":"thrawn"}`,
+		`{"level":"info","msg":"not an error"}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	require.NoError(t, run(strings.NewReader(input), &out, false))
+
+	assert.Contains(t, out.String(), `error: while fetching: while reading: EOF
+  type: *errors.wrappedError
+  code: fetch.failed
+  chain:
+    while fetching
+      while reading
+        EOF
+  fields:
+    attempt = 2
+    domain = example.com
+  stack:
+    errfmt.TestRun
+`)
+	assert.Regexp(t, `(?m)^    errfmt\.TestRun\n      \S*main_test\.go:\d+$`, out.String())
+
+	assert.Contains(t, out.String(), `error: legacy: EOF
+  type: *errors.wrappedError
+  fields:
+    user = thrawn
+  stack:
+    main.run
+      main.go:10
+`)
+	assert.NotContains(t, out.String(), "not an error")
+	assert.NotContains(t, out.String(), "\x1b[")
+
+	out.Reset()
+	require.NoError(t, run(strings.NewReader(string(b)), &out, true))
+	assert.Contains(t, out.String(), "\x1b[1m\x1b[31merror:\x1b[0m")
+}
@@ -0,0 +1,97 @@
+package errors
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// ContextExtractor returns the fields found in ctx which should be attached to errors
+// wrapped using WrapCtx(), such as the tenant or the request id.
+//
+//	errors.RegisterContextExtractor(func(ctx context.Context) errors.Fields {
+//		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+//			return errors.Fields{"tenant": tenant}
+//		}
+//		return nil
+//	})
+type ContextExtractor func(ctx context.Context) Fields
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   []*ContextExtractor
+)
+
+// RegisterContextExtractor adds an extractor run by WrapCtx(). Extractors are run in the
+// order they were registered, when more than one returns the same key the last one wins.
+// It is safe to call from init() in multiple packages. RegisterContextExtractor returns
+// a function which removes the extractor.
+func RegisterContextExtractor(e ContextExtractor) (remove func()) {
+	entry := &e
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors = append(extractors, entry)
+	return func() {
+		extractorsMu.Lock()
+		defer extractorsMu.Unlock()
+		extractors = without(extractors, entry)
+	}
+}
+
+// WrapCtx returns a new error wrapping err with a stack trace, the message and fields
+// describing the state of ctx at the time of the wrap, such that an error caused by
+// "context deadline exceeded" shows how much of the budget remained and who canceled.
+// The fields describing the state of ctx are
+//
+//   - ctx.done is true if ctx was already done
+//   - ctx.err is the error of ctx if it was done
+//   - ctx.cause is the cause of ctx (see context.WithCancelCause()) if it differs from ctx.err
+//   - ctx.deadline_remaining is the time remaining until the deadline of ctx, negative
+//     if the deadline has passed. It is omitted if ctx has no deadline.
+//
+// The request id found using RequestIDContextKeys and the fields returned by each of
+// the registered extractors (see RegisterContextExtractor()) are also attached, although
+// they cannot replace the fields describing the state of ctx.
+//
+//	if err := client.Do(ctx, req); err != nil {
+//		return errors.WrapCtx(ctx, err, "while calling upstream")
+//	}
+//
+// If err is nil, WrapCtx returns nil.
+func WrapCtx(ctx context.Context, err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  setOf(contextFields(ctx)),
+		wrapped: err,
+		msg:     msg,
+	})
+}
+
+func contextFields(ctx context.Context) Fields {
+	var f Fields
+	if id, ok := requestIDFromContext(ctx); ok {
+		f = f.set(RequestIDField, id)
+	}
+	extractorsMu.RLock()
+	for _, e := range extractors {
+		f = f.Merge((*e)(ctx))
+	}
+	extractorsMu.RUnlock()
+
+	f = f.set("ctx.done", ctx.Err() != nil)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		f["ctx.err"] = ctxErr.Error()
+		if cause := context.Cause(ctx); cause != nil && cause != ctxErr {
+			f["ctx.cause"] = cause.Error()
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		f["ctx.deadline_remaining"] = deadline.Sub(NowFunc())
+	}
+	return f
+}
@@ -0,0 +1,76 @@
+package errors_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapCtx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	err := errors.WrapCtx(ctx, io.EOF, "while calling upstream")
+	assert.Equal(t, "while calling upstream: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, false, m["ctx.done"])
+	assert.NotContains(t, m, "ctx.err")
+	assert.InDelta(t, time.Hour, m["ctx.deadline_remaining"], float64(time.Minute))
+	assert.Equal(t, "errors_test.TestWrapCtx", m["excFuncName"])
+
+	assert.Nil(t, errors.WrapCtx(ctx, nil, "nil"))
+}
+
+func TestWrapCtxDone(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("client disconnected"))
+
+	m := errors.ToMap(errors.WrapCtx(ctx, context.Canceled, "while calling upstream"))
+	assert.Equal(t, true, m["ctx.done"])
+	assert.Equal(t, "context canceled", m["ctx.err"])
+	assert.Equal(t, "client disconnected", m["ctx.cause"])
+	assert.NotContains(t, m, "ctx.deadline_remaining")
+
+	ctx, cancelDeadline := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelDeadline()
+
+	m = errors.ToMap(errors.WrapCtx(ctx, context.DeadlineExceeded, "while calling upstream"))
+	assert.Equal(t, "context deadline exceeded", m["ctx.err"])
+	assert.NotContains(t, m, "ctx.cause")
+	assert.Less(t, m["ctx.deadline_remaining"], time.Duration(0))
+}
+
+type tenantKey struct{}
+
+func TestRegisterContextExtractor(t *testing.T) {
+	remove := errors.RegisterContextExtractor(func(ctx context.Context) errors.Fields {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return errors.Fields{"tenant": tenant}
+		}
+		return nil
+	})
+	defer remove()
+	removeState := errors.RegisterContextExtractor(func(ctx context.Context) errors.Fields {
+		return errors.Fields{"ctx.done": "extractors cannot replace state fields"}
+	})
+	defer removeState()
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	m := errors.ToMap(errors.WrapCtx(ctx, io.EOF, "while calling upstream"))
+	assert.Equal(t, "acme", m["tenant"])
+	assert.Equal(t, false, m["ctx.done"])
+
+	m = errors.ToMap(errors.WrapCtx(context.Background(), io.EOF, "while calling upstream"))
+	assert.NotContains(t, m, "tenant")
+
+	// Once removed, the extractor is no longer run
+	remove()
+	m = errors.ToMap(errors.WrapCtx(ctx, io.EOF, "while calling upstream"))
+	assert.NotContains(t, m, "tenant")
+}
@@ -0,0 +1,66 @@
+package errors
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// The following environment variables are read once at init, such that stack
+// capture and redaction can be tuned per deployment without code changes.
+//
+//	MG_ERRORS_STACKS=off          disables stack capture, see callstack.CaptureStacks
+//	MG_ERRORS_DEPTH=64            the maximum stack depth, see callstack.MaxDepth
+//	MG_ERRORS_REDACT=token,secret field keys to redact, see RegisterRedactor and
+//	                              DefaultExportOptions
+//
+// Values which cannot be parsed are ignored. Configuration assigned by code after
+// init takes precedence over the environment.
+const (
+	EnvStacks = "MG_ERRORS_STACKS"
+	EnvDepth  = "MG_ERRORS_DEPTH"
+	EnvRedact = "MG_ERRORS_REDACT"
+)
+
+func init() {
+	configureFromEnv()
+}
+
+func configureFromEnv() {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(EnvStacks))) {
+	case "off", "false", "0", "no":
+		callstack.CaptureStacks = false
+	}
+
+	if depth, err := strconv.Atoi(strings.TrimSpace(os.Getenv(EnvDepth))); err == nil && depth > 0 {
+		callstack.MaxDepth = depth
+	}
+
+	var redact []string
+	for _, key := range strings.Split(os.Getenv(EnvRedact), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			redact = append(redact, key)
+		}
+	}
+	if len(redact) != 0 {
+		DefaultExportOptions.RedactFields = append(DefaultExportOptions.RedactFields, redact...)
+		RegisterRedactor(keyRedactor(redact...))
+	}
+}
+
+// keyRedactor returns a Redactor which replaces the values of the fields with
+// the keys provided with Redacted.
+func keyRedactor(keys ...string) Redactor {
+	set := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		set[key] = struct{}{}
+	}
+	return func(key string, _ any) (any, bool) {
+		if _, ok := set[key]; !ok {
+			return nil, false
+		}
+		return Redacted, true
+	}
+}
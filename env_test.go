@@ -0,0 +1,44 @@
+package errors_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvConfig(t *testing.T) {
+	if os.Getenv("TEST_ENV_CONFIG") == "1" {
+		_ = errors.ToMap(errors.Wrap(io.EOF, "while reading"))
+		err := errors.Fields{"token": "secret", "domain": "example.com"}.Wrap(io.EOF, "while reading")
+		fmt.Printf("stacks=%t depth=%d redact=%v frames=%d token=%v,%v,%v domain=%v\n",
+			callstack.CaptureStacks,
+			callstack.MaxDepth,
+			errors.DefaultExportOptions.RedactFields,
+			len(errors.ToEnvelope(errors.Wrap(io.EOF, "while reading")).Frames),
+			errors.ToMap(err)["token"],
+			errors.FieldsOf(err)["token"],
+			errors.ToEnvelope(err).Fields["token"],
+			errors.ToMap(err)["domain"],
+		)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestEnvConfig$")
+	cmd.Env = append(os.Environ(),
+		"TEST_ENV_CONFIG=1",
+		errors.EnvStacks+"=off",
+		errors.EnvDepth+"=64",
+		errors.EnvRedact+"=token, password",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "stacks=false depth=64 redact=[token password] frames=0 "+
+		"token=[REDACTED],[REDACTED],[REDACTED] domain=example.com")
+}
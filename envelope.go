@@ -0,0 +1,156 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// EnvelopeVersion is the version of the Envelope schema produced by this package.
+// It is incremented whenever the meaning of an existing field changes. Adding a
+// field does not change the version, as decoders ignore fields they do not know.
+const EnvelopeVersion = 1
+
+// Envelope is the versioned wire representation of an error, used to exchange
+// errors between services which might be built with different versions of this package.
+type Envelope struct {
+	Version  int                     `json:"version"`
+	Message  string                  `json:"message"`
+	Type     string                  `json:"type,omitempty"`
+	Messages []string                `json:"messages,omitempty"`
+	Code     string                  `json:"code,omitempty"`
+	Kind     Kind                    `json:"kind,omitempty"`
+	ID       string                  `json:"id,omitempty"`
+	Status   int                     `json:"status,omitempty"`
+	Fields   map[string]any          `json:"fields,omitempty"`
+	Frames   []callstack.FrameRecord `json:"frames,omitempty"`
+
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+// ToEnvelope returns the wire representation of err.
+// If err is nil, ToEnvelope returns nil.
+func ToEnvelope(err error) *Envelope {
+	if err == nil {
+		return nil
+	}
+	env := Envelope{
+		Version:  EnvelopeVersion,
+		Message:  MessageOf(err),
+		Type:     typeName(Unwrap(err)),
+		Messages: chainMessages(err),
+		Code:     CodeOf(err),
+		Kind:     KindOf(err),
+		ID:       IDOf(err),
+		Status:   StatusOf(err),
+
+		Breadcrumbs: Breadcrumbs(err),
+	}
+	var f HasFields
+	if As(err, &f) {
+		env.Fields = redactFields(f.HasFields())
+	}
+	if trace, ok := StackOf(err); ok {
+		env.Frames = trace.Records()
+	}
+
+	// Errors decoded by gob carry the type and frames of the original error
+	var decoded *decodedError
+	if As(err, &decoded) {
+		env.Type = decoded.env.Type
+		if len(env.Frames) == 0 {
+			env.Frames = decoded.env.Frames
+		}
+	}
+	return &env
+}
+
+// ToJSON returns the JSON encoding of the Envelope for err, with only the fields
+// permitted by SetExportAllowlist()
+func ToJSON(err error) ([]byte, error) {
+	return json.Marshal(ToEnvelope(err).Exportable())
+}
+
+// ParseJSON decodes an Envelope produced by ToJSON() from any version of this package.
+// Documents without a version are decoded as the JSON encoding of a ToMap() result,
+// which is treated as version 0.
+func ParseJSON(b []byte) (*Envelope, error) {
+	var probe struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, fmt.Errorf("while decoding error envelope: %w", err)
+	}
+	if probe.Version == nil {
+		return parseLegacy(b)
+	}
+
+	// Newer versions are decoded on a best effort basis, as unknown fields are ignored
+	var env Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("while decoding error envelope: %w", err)
+	}
+	return &env, nil
+}
+
+// parseLegacy decodes the JSON encoding of a ToMap() result
+func parseLegacy(b []byte) (*Envelope, error) {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("while decoding error envelope: %w", err)
+	}
+	env := Envelope{Fields: map[string]any{}}
+	for key, value := range m {
+		if !strings.HasPrefix(key, "exc") {
+			env.Fields[key] = value
+		}
+	}
+	env.Message, _ = m["excValue"].(string)
+	env.Type, _ = m["excType"].(string)
+	if fn, ok := m["excFuncName"].(string); ok {
+		frame := callstack.FrameRecord{Func: fn}
+		frame.File, _ = m["excFileName"].(string)
+		if line, ok := m["excLineNum"].(float64); ok {
+			frame.Line = int(line)
+		}
+		env.Frames = []callstack.FrameRecord{frame}
+	}
+	return &env, nil
+}
+
+// GobEncode implements gob.GobEncoder using the JSON encoding of the envelope, such
+// that field values of any type can be encoded without registering them with gob,
+// and the envelope is decoded using the same version compatibility rules as ParseJSON().
+func (e *Envelope) GobEncode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// GobDecode implements gob.GobDecoder
+func (e *Envelope) GobDecode(b []byte) error {
+	env, err := ParseJSON(b)
+	if err != nil {
+		return err
+	}
+	*e = *env
+	return nil
+}
+
+// ToError reconstructs an error from the envelope which carries the message, code,
+// kind, id, status and fields of the original error. The stack trace of the original
+// error is not attached, but is available in Frames.
+func (e *Envelope) ToError() error {
+	err := WrapOpts(New(e.Message), NoMsg,
+		WithCode(e.Code),
+		WithKind(e.Kind),
+		WithID(e.ID),
+		WithStatus(e.Status),
+		WithFieldsOpt(e.Fields),
+		NoStack(),
+	)
+	if len(e.Breadcrumbs) != 0 {
+		return &breadcrumbs{wrapped: err, crumbs: e.Breadcrumbs}
+	}
+	return err
+}
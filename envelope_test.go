@@ -0,0 +1,97 @@
+package errors_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while fetching",
+		errors.WithCode("domain.not_found"),
+		errors.WithKind("not_found"),
+		errors.WithID("abc123"),
+		errors.WithStatus(http.StatusNotFound),
+		errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com"}),
+	)
+	err = errors.Wrap(err, "top")
+
+	b, jerr := errors.ToJSON(err)
+	require.NoError(t, jerr)
+
+	env, jerr := errors.ParseJSON(b)
+	require.NoError(t, jerr)
+	assert.Equal(t, errors.EnvelopeVersion, env.Version)
+	assert.Equal(t, "top: while fetching: EOF", env.Message)
+	assert.Equal(t, []string{"top", "while fetching", "EOF"}, env.Messages)
+	assert.Equal(t, "domain.not_found", env.Code)
+	assert.Equal(t, errors.Kind("not_found"), env.Kind)
+	assert.Equal(t, "abc123", env.ID)
+	assert.Equal(t, http.StatusNotFound, env.Status)
+	assert.Equal(t, map[string]any{"domain.id": "example.com"}, env.Fields)
+	require.NotEmpty(t, env.Frames)
+	assert.Equal(t, "errors_test.TestEnvelope", env.Frames[0].Func)
+
+	decoded := env.ToError()
+	assert.Equal(t, "top: while fetching: EOF", decoded.Error())
+	assert.Equal(t, "domain.not_found", errors.CodeOf(decoded))
+	assert.Equal(t, "example.com", errors.ToMap(decoded)["domain.id"])
+
+	assert.Nil(t, errors.ToEnvelope(nil))
+}
+
+func TestParseJSONCompatibility(t *testing.T) {
+	t.Run("version 0 is a ToMap() result", func(t *testing.T) {
+		env, err := errors.ParseJSON([]byte(`{"excValue":"message: EOF","excType":"*errors.errorString",
+			"excFuncName":"main.run","excFileName":"/src/main.go","excLineNum":42,"key1":"value1"}`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, env.Version)
+		assert.Equal(t, "message: EOF", env.Message)
+		assert.Equal(t, "*errors.errorString", env.Type)
+		assert.Equal(t, map[string]any{"key1": "value1"}, env.Fields)
+		require.Len(t, env.Frames, 1)
+		assert.Equal(t, "main.run", env.Frames[0].Func)
+		assert.Equal(t, 42, env.Frames[0].Line)
+	})
+
+	t.Run("newer versions ignore unknown fields", func(t *testing.T) {
+		env, err := errors.ParseJSON([]byte(`{"version":99,"message":"EOF","future":{"a":1}}`))
+		require.NoError(t, err)
+		assert.Equal(t, 99, env.Version)
+		assert.Equal(t, "EOF", env.Message)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := errors.ParseJSON([]byte(`{`))
+		assert.Error(t, err)
+	})
+}
+
+func TestEnvelopeGob(t *testing.T) {
+	type reply struct {
+		Result string
+		Err    *errors.Envelope
+	}
+	err := errors.WrapOpts(io.EOF, "while fetching",
+		errors.WithCode("domain.not_found"),
+		errors.WithFieldsOpt(errors.Fields{"elapsed": time.Second, "domain.id": "example.com"}),
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(reply{Err: errors.ToEnvelope(err)}))
+
+	var decoded reply
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	require.NotNil(t, decoded.Err)
+	assert.Equal(t, "while fetching: EOF", decoded.Err.Message)
+	assert.Equal(t, "domain.not_found", decoded.Err.Code)
+	assert.Equal(t, "example.com", decoded.Err.Fields["domain.id"])
+	assert.Equal(t, "while fetching: EOF", decoded.Err.ToError().Error())
+}
@@ -0,0 +1,347 @@
+// Package errgrpc propagates error context between services over gRPC using
+// trailer metadata, such that the error ID and selected fields from the server
+// are re-attached to the error returned to the client.
+package errgrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/mailgun/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Trailer keys used to propagate the error between services
+const (
+	TrailerID     = "x-mailgun-error-id"
+	TrailerFields = "x-mailgun-error-fields"
+)
+
+// UnaryServerInterceptor returns an interceptor which adds the error ID and the fields
+// named in safeFields to the trailer when the handler returns an error. Only the fields
+// named and permitted by errors.SetExportAllowlist() are included, as all other fields
+// might contain information which should not leave the service. Errors without a gRPC
+// status are returned with the code their Kind is mapped to (see Code()).
+//
+//	grpc.NewServer(grpc.ChainUnaryInterceptor(errgrpc.UnaryServerInterceptor("domain.id")))
+func UnaryServerInterceptor(safeFields ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if md := Trailer(err, safeFields...); md != nil {
+			_ = grpc.SetTrailer(ctx, md)
+		}
+		return resp, withStatus(err)
+	}
+}
+
+// StreamServerInterceptor is identical to UnaryServerInterceptor but for streaming handlers
+func StreamServerInterceptor(safeFields ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		if md := Trailer(err, safeFields...); md != nil {
+			ss.SetTrailer(md)
+		}
+		return withStatus(err)
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor which re-attaches the error ID and fields
+// found in the trailer to the error returned by the call. The returned error still
+// works with status.FromError() and status.Code().
+//
+//	grpc.NewClient(target, grpc.WithChainUnaryInterceptor(errgrpc.UnaryClientInterceptor()))
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var md metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&md))...)
+		return FromTrailer(err, md)
+	}
+}
+
+// StreamClientInterceptor is identical to UnaryClientInterceptor but for streaming calls.
+// As the trailer is only available once the stream has ended, the error ID and fields
+// are re-attached to the error returned by RecvMsg(). io.EOF is returned unchanged.
+//
+//	grpc.NewClient(target, grpc.WithChainStreamInterceptor(errgrpc.StreamClientInterceptor()))
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &clientStream{ClientStream: s}, nil
+	}
+}
+
+// clientStream re-attaches the trailer to the errors returned by RecvMsg()
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return FromTrailer(err, s.Trailer())
+}
+
+var (
+	kindCodeMu sync.RWMutex
+	kindCode   = map[errors.Kind]codes.Code{
+		errors.KindInvalidArgument:  codes.InvalidArgument,
+		errors.KindUnauthenticated:  codes.Unauthenticated,
+		errors.KindPermissionDenied: codes.PermissionDenied,
+		errors.KindNotFound:         codes.NotFound,
+		errors.KindAlreadyExists:    codes.AlreadyExists,
+		errors.KindRateLimited:      codes.ResourceExhausted,
+		errors.KindCanceled:         codes.Canceled,
+		errors.KindInternal:         codes.Internal,
+		errors.KindUnimplemented:    codes.Unimplemented,
+		errors.KindUnavailable:      codes.Unavailable,
+		errors.KindTimeout:          codes.DeadlineExceeded,
+	}
+	codeKind = reverseKinds(kindCode)
+)
+
+// RegisterKindCode maps kind to the gRPC code, replacing the canonical mapping if kind
+// is part of the taxonomy. The code is also mapped back to kind by KindForCode(), and
+// the code kind was previously mapped to is no longer mapped back to it. It should only
+// be called once at startup, typically from init(). RegisterKindCode returns a function
+// which restores the previous mapping.
+//
+//	errgrpc.RegisterKindCode("quota_exceeded", codes.ResourceExhausted)
+func RegisterKindCode(kind errors.Kind, code codes.Code) (remove func()) {
+	kindCodeMu.Lock()
+	defer kindCodeMu.Unlock()
+	prevCode, hadCode := kindCode[kind]
+	prevKind, hadKind := codeKind[code]
+	setKindCode(kind, code)
+	return func() {
+		kindCodeMu.Lock()
+		defer kindCodeMu.Unlock()
+		if codeKind[code] == kind {
+			delete(codeKind, code)
+		}
+		delete(kindCode, kind)
+		if hadCode {
+			setKindCode(kind, prevCode)
+		}
+		if hadKind {
+			codeKind[code] = prevKind
+		}
+	}
+}
+
+// setKindCode maps kind to code in both directions, the caller must hold kindCodeMu
+func setKindCode(kind errors.Kind, code codes.Code) {
+	if old, ok := kindCode[kind]; ok && codeKind[old] == kind {
+		delete(codeKind, old)
+	}
+	kindCode[kind] = code
+	codeKind[code] = kind
+}
+
+// KindForCode returns the Kind the gRPC code is mapped to. It returns "" if
+// code is not mapped.
+func KindForCode(code codes.Code) errors.Kind {
+	kindCodeMu.RLock()
+	defer kindCodeMu.RUnlock()
+	return codeKind[code]
+}
+
+func reverseKinds(m map[errors.Kind]codes.Code) map[codes.Code]errors.Kind {
+	result := make(map[codes.Code]errors.Kind, len(m))
+	for kind, code := range m {
+		result[code] = kind
+	}
+	return result
+}
+
+// Code returns the gRPC code which should be returned to the client for err. It is
+// the code of the gRPC status in err's chain if any, otherwise the code the Kind of
+// err is mapped to by RegisterKindCode() or the canonical taxonomy, otherwise
+// codes.Unknown. If err is nil, it returns codes.OK.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	kindCodeMu.RLock()
+	defer kindCodeMu.RUnlock()
+	if code, ok := kindCode[errors.KindOf(err)]; ok {
+		return code
+	}
+	return codes.Unknown
+}
+
+// withStatus wraps err with the gRPC status for its Kind, if err does not already
+// carry a gRPC status. The chain is preserved for interceptors further up the chain.
+func withStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := Code(err)
+	if code == codes.Unknown {
+		return err
+	}
+	return &statusError{error: err, status: status.New(code, errors.MessageOf(err))}
+}
+
+type statusError struct {
+	error
+	status *status.Status
+}
+
+func (e *statusError) Unwrap() error {
+	return e.error
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (e *statusError) Cause() error { return e.error }
+
+func (e *statusError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// Trailer returns the trailer metadata for err, containing the error ID and the fields
+// named in safeFields. Returns nil if err is nil or there is nothing to propagate.
+func Trailer(err error, safeFields ...string) metadata.MD {
+	if err == nil {
+		return nil
+	}
+	md := metadata.MD{}
+	if id := errors.IDOf(err); id != "" {
+		md.Set(TrailerID, id)
+	}
+
+	all := errors.ToMap(err)
+	values := url.Values{}
+	for _, key := range safeFields {
+		if value, ok := all[key]; ok && errors.ExportAllowed(key) {
+			values.Set(key, fmt.Sprint(value))
+		}
+	}
+	if len(values) != 0 {
+		md.Set(TrailerFields, values.Encode())
+	}
+
+	if len(md) == 0 {
+		return nil
+	}
+	return md
+}
+
+// FromTrailer wraps err with the error ID and fields found in the trailer metadata.
+// If err has no Kind, it is given the Kind the code of its gRPC status is mapped to
+// by KindForCode(). If err is nil or there is nothing to propagate, err is returned
+// unchanged.
+func FromTrailer(err error, md metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+	var id string
+	if values := md.Get(TrailerID); len(values) != 0 {
+		id = values[0]
+	}
+
+	fields := errors.Fields{}
+	if values := md.Get(TrailerFields); len(values) != 0 {
+		// Malformed fields are ignored, as the error itself is still useful
+		decoded, _ := url.ParseQuery(values[0])
+		keys := make([]string, 0, len(decoded))
+		for key := range decoded {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fields[key] = decoded.Get(key)
+		}
+	}
+
+	var kind errors.Kind
+	if s, ok := status.FromError(err); ok && errors.KindOf(err) == "" {
+		kind = KindForCode(s.Code())
+	}
+
+	if id == "" && kind == "" && len(fields) == 0 {
+		return err
+	}
+	return errors.WrapOpts(err, errors.NoMsg,
+		errors.WithID(id),
+		errors.WithKind(kind),
+		errors.WithFieldsOpt(fields),
+		errors.NoStack(),
+	)
+}
+
+// RequestIDExtractor returns the x-request-id of the incoming metadata under
+// errors.RequestIDField. It is not registered by this package, register it at
+// startup such that errors.WrapCtx() picks up the request id of gRPC calls.
+//
+//	errors.RegisterContextExtractor(errgrpc.RequestIDExtractor)
+func RequestIDExtractor(ctx context.Context) errors.Fields {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	if values := md.Get("x-request-id"); len(values) != 0 && values[0] != "" {
+		return errors.Fields{errors.RequestIDField: values[0]}
+	}
+	return nil
+}
+
+// MetadataKeys is the allowlist of incoming metadata keys WithContext() attaches to
+// errors. Keys which are not on this list are never attached, as they might contain
+// credentials. It should only be modified once at startup.
+var MetadataKeys = []string{"user-agent", "x-request-id"}
+
+// WithContext returns a new error wrapping err with fields describing the gRPC call
+// found in the server context; the full method name and the incoming metadata found
+// in MetadataKeys. The x-request-id metadata is also attached under errors.RequestIDField.
+// It is the gRPC equivalent of errors.WithRequest().
+//
+//	func (s *Server) GetDomain(ctx context.Context, req *pb.GetDomainRequest) (*pb.Domain, error) {
+//		...
+//		if err != nil {
+//			return nil, errgrpc.WithContext(ctx, err)
+//		}
+//	}
+//
+// If err is nil, WithContext returns nil.
+func WithContext(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	f := errors.Fields{}
+	if method, ok := grpc.Method(ctx); ok {
+		f["grpc.method"] = method
+	}
+	f = f.Merge(RequestIDExtractor(ctx))
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, key := range MetadataKeys {
+			if values := md.Get(key); len(values) != 0 {
+				f["grpc.metadata."+key] = values[0]
+			}
+		}
+	}
+	return errors.WrapOpts(err, errors.NoMsg, errors.WithFieldsOpt(f), errors.Skip(1))
+}
@@ -0,0 +1,227 @@
+package errgrpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/errgrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	err error
+}
+
+func (h *healthServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return nil, h.err
+}
+
+func TestInterceptors(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(errgrpc.UnaryServerInterceptor("domain.id", "missing")))
+	healthpb.RegisterHealthServer(srv, &healthServer{
+		err: errors.WrapOpts(status.Error(codes.NotFound, "domain not found"), "while checking",
+			errors.WithID("abc123"),
+			errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com", "password": "secret"}),
+		),
+	})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(errgrpc.UnaryClientInterceptor()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	assert.Equal(t, "abc123", errors.IDOf(err))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "example.com", m["domain.id"])
+	assert.NotContains(t, m, "password")
+}
+
+func (h *healthServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	return h.err
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(grpc.ChainStreamInterceptor(errgrpc.StreamServerInterceptor("domain.id")))
+	healthpb.RegisterHealthServer(srv, &healthServer{
+		err: errors.WrapOpts(status.Error(codes.NotFound, "domain not found"), "while watching",
+			errors.WithID("abc123"),
+			errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com", "password": "secret"}),
+		),
+	})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainStreamInterceptor(errgrpc.StreamClientInterceptor()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	_, err = stream.Recv()
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "abc123", errors.IDOf(err))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "example.com", m["domain.id"])
+	assert.NotContains(t, m, "password")
+}
+
+func TestTrailer(t *testing.T) {
+	assert.Nil(t, errgrpc.Trailer(nil))
+	assert.Nil(t, errgrpc.Trailer(io.EOF, "domain.id"))
+
+	md := errgrpc.Trailer(errors.WrapKV(io.EOF, "message", "domain.id", "example.com"), "domain.id")
+	assert.Equal(t, metadata.Pairs(errgrpc.TrailerFields, "domain.id=example.com"), md)
+}
+
+func TestFromTrailer(t *testing.T) {
+	assert.NoError(t, errgrpc.FromTrailer(nil, metadata.Pairs(errgrpc.TrailerID, "abc123")))
+	assert.Equal(t, io.EOF, errgrpc.FromTrailer(io.EOF, nil))
+
+	err := errgrpc.FromTrailer(io.EOF, metadata.Pairs(errgrpc.TrailerID, "abc123"))
+	assert.Equal(t, "EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	// The Kind is recovered from the code of the gRPC status
+	err = errgrpc.FromTrailer(status.Error(codes.NotFound, "not found"), nil)
+	assert.Equal(t, errors.KindNotFound, errors.KindOf(err))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	unknown := status.Error(codes.Unknown, "unknown")
+	assert.Equal(t, unknown, errgrpc.FromTrailer(unknown, nil))
+}
+
+type serverStream struct {
+	grpc.ServerTransportStream
+	method string
+}
+
+func (s *serverStream) Method() string {
+	return s.method
+}
+
+func TestWithContext(t *testing.T) {
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(),
+		&serverStream{method: "/grpc.health.v1.Health/Check"})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(
+		"x-request-id", "abc123",
+		"authorization", "Bearer secret",
+	))
+
+	err := errgrpc.WithContext(ctx, io.EOF)
+	assert.Equal(t, "EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "/grpc.health.v1.Health/Check", m["grpc.method"])
+	assert.Equal(t, "abc123", m["grpc.metadata.x-request-id"])
+	assert.Equal(t, "abc123", m[errors.RequestIDField])
+	assert.NotContains(t, m, "grpc.metadata.authorization")
+	assert.Equal(t, "errgrpc_test.TestWithContext", m["excFuncName"])
+
+	// errors.WrapCtx() picks up the request id from the metadata once the extractor is registered
+	m = errors.ToMap(errors.WrapCtx(ctx, io.EOF, "while checking"))
+	assert.NotContains(t, m, errors.RequestIDField)
+	remove := errors.RegisterContextExtractor(errgrpc.RequestIDExtractor)
+	defer remove()
+	m = errors.ToMap(errors.WrapCtx(ctx, io.EOF, "while checking"))
+	assert.Equal(t, "abc123", m[errors.RequestIDField])
+
+	assert.Nil(t, errgrpc.WithContext(ctx, nil))
+}
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, codes.OK, errgrpc.Code(nil))
+	assert.Equal(t, codes.Unknown, errgrpc.Code(io.EOF))
+	assert.Equal(t, codes.NotFound, errgrpc.Code(errors.WrapOpts(io.EOF, "not found",
+		errors.WithKind(errors.KindNotFound))))
+
+	// A gRPC status in the chain takes precedence over the Kind
+	err := errors.WrapOpts(status.Error(codes.Aborted, "aborted"), "while writing",
+		errors.WithKind(errors.KindNotFound))
+	assert.Equal(t, codes.Aborted, errgrpc.Code(err))
+
+	remove := errgrpc.RegisterKindCode("quota_exceeded", codes.ResourceExhausted)
+	assert.Equal(t, codes.ResourceExhausted, errgrpc.Code(errors.WrapOpts(io.EOF, "quota",
+		errors.WithKind("quota_exceeded"))))
+	assert.Equal(t, errors.Kind("quota_exceeded"), errgrpc.KindForCode(codes.ResourceExhausted))
+
+	remove()
+	assert.Equal(t, codes.Unknown, errgrpc.Code(errors.WrapOpts(io.EOF, "quota",
+		errors.WithKind("quota_exceeded"))))
+	assert.Equal(t, errors.KindRateLimited, errgrpc.KindForCode(codes.ResourceExhausted))
+}
+
+func TestKindForCode(t *testing.T) {
+	assert.Equal(t, errors.KindNotFound, errgrpc.KindForCode(codes.NotFound))
+	assert.Equal(t, errors.Kind(""), errgrpc.KindForCode(codes.OK))
+
+	// Remapping a kind drops the stale reverse mapping
+	remove := errgrpc.RegisterKindCode(errors.KindNotFound, codes.FailedPrecondition)
+	assert.Equal(t, errors.KindNotFound, errgrpc.KindForCode(codes.FailedPrecondition))
+	assert.Equal(t, errors.Kind(""), errgrpc.KindForCode(codes.NotFound))
+
+	remove()
+	assert.Equal(t, errors.KindNotFound, errgrpc.KindForCode(codes.NotFound))
+	assert.Equal(t, errors.Kind(""), errgrpc.KindForCode(codes.FailedPrecondition))
+}
+
+func TestInterceptorKind(t *testing.T) {
+	interceptor := errgrpc.UnaryServerInterceptor()
+	cause := errors.WrapOpts(io.EOF, "while fetching domain", errors.WithKind(errors.KindNotFound))
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{},
+		func(context.Context, any) (any, error) { return nil, cause })
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "while fetching domain: EOF", status.Convert(err).Message())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	// Code depending on github.com/pkg/errors.Cause() reaches the cause
+	causer, ok := err.(interface{ Cause() error })
+	require.True(t, ok)
+	assert.Equal(t, cause, causer.Cause())
+}
+
+func TestTrailerExportAllowlist(t *testing.T) {
+	errors.SetExportAllowlist("domain.id")
+	defer errors.SetExportAllowlist()
+
+	err := errors.WrapKV(io.EOF, "message", "domain.id", "example.com", "password", "secret")
+	md := errgrpc.Trailer(err, "domain.id", "password")
+	assert.Equal(t, metadata.Pairs(errgrpc.TrailerFields, "domain.id=example.com"), md)
+}
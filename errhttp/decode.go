@@ -0,0 +1,120 @@
+package errhttp
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/mailgun/errors"
+)
+
+// MaxBodySize is the number of bytes of the response body read by DecodeResponse(),
+// the rest of the body is ignored. It should only be modified once at startup.
+var MaxBodySize int64 = 1 << 20
+
+// DecodeResponse reconstructs the error returned by a service from the response, such
+// that clients get the message, code, kind, id and fields of the upstream error instead
+// of only the status. The body is decoded according to its content type.
+//
+//	application/json          the Envelope produced by errors.ToJSON()
+//	application/problem+json  an RFC 9457 problem details document
+//
+// The message of a problem document is its detail or otherwise its title, and the
+// extension members code, kind and id classify the error, while all other extension
+// members are attached as fields. Anything missing from the body, or a body which cannot
+// be decoded, is taken from the X-Mailgun-Error-* headers, see FromResponse().
+//
+//	resp, err := client.Do(req)
+//	if err != nil {
+//		return err
+//	}
+//	defer resp.Body.Close()
+//	if err := errhttp.DecodeResponse(resp); err != nil {
+//		return errors.Wrap(err, "while calling upstream")
+//	}
+//
+// The body is read but not closed. Returns nil if the response status is not an
+// error and no error headers are present.
+func DecodeResponse(resp *http.Response) error {
+	env := fromHeaders(resp.Header, resp.StatusCode)
+	if env == nil || resp.Body == nil {
+		return FromResponse(resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	if err != nil || len(body) == 0 {
+		return env.ToError()
+	}
+
+	var decoded *errors.Envelope
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		decoded, _ = errors.ParseJSON(body)
+	case "application/problem+json":
+		decoded = parseProblem(body)
+	}
+	if decoded == nil || decoded.Message == "" {
+		return env.ToError()
+	}
+	return mergeEnvelopes(decoded, env).ToError()
+}
+
+// problemMembers are the members of a problem details document which are not extensions
+var problemMembers = map[string]bool{
+	"type": true, "title": true, "status": true, "detail": true, "instance": true,
+	"code": true, "kind": true, "id": true,
+}
+
+// parseProblem decodes an RFC 9457 problem details document, nil if it is malformed
+func parseProblem(b []byte) *errors.Envelope {
+	var members map[string]any
+	if err := json.Unmarshal(b, &members); err != nil {
+		return nil
+	}
+	env := errors.Envelope{Version: errors.EnvelopeVersion, Fields: map[string]any{}}
+	str := func(key string) string {
+		s, _ := members[key].(string)
+		return s
+	}
+	env.Message = str("detail")
+	if env.Message == "" {
+		env.Message = str("title")
+	}
+	if status, ok := members["status"].(float64); ok {
+		env.Status = int(status)
+	}
+	env.Code, env.Kind, env.ID = str("code"), errors.Kind(str("kind")), str("id")
+	for key, value := range members {
+		if !problemMembers[key] {
+			env.Fields[key] = value
+		}
+	}
+	return &env
+}
+
+// mergeEnvelopes fills what is missing from the decoded body using the headers
+func mergeEnvelopes(body, headers *errors.Envelope) *errors.Envelope {
+	if body.Status == 0 {
+		body.Status = headers.Status
+	}
+	if body.Code == "" {
+		body.Code = headers.Code
+	}
+	if body.Kind == "" {
+		body.Kind = headers.Kind
+	}
+	if body.ID == "" {
+		body.ID = headers.ID
+	}
+	for key, value := range headers.Fields {
+		if _, ok := body.Fields[key]; !ok {
+			if body.Fields == nil {
+				body.Fields = map[string]any{}
+			}
+			body.Fields[key] = value
+		}
+	}
+	return body
+}
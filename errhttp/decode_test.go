@@ -0,0 +1,87 @@
+package errhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/errhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func response(status int, contentType, body string) *http.Response {
+	rec := httptest.NewRecorder()
+	if contentType != "" {
+		rec.Header().Set("Content-Type", contentType)
+	}
+	rec.WriteHeader(status)
+	_, _ = io.WriteString(rec, body)
+	return rec.Result()
+}
+
+func TestDecodeResponse(t *testing.T) {
+	t.Run("Envelope", func(t *testing.T) {
+		upstream := errors.WrapOpts(io.EOF, "while fetching domain",
+			errors.WithCode("domain.not_found"),
+			errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com"}))
+		b, err := errors.ToJSON(upstream)
+		require.NoError(t, err)
+
+		err = errhttp.DecodeResponse(response(http.StatusNotFound, "application/json; charset=utf-8", string(b)))
+		require.Error(t, err)
+		assert.Equal(t, "while fetching domain: EOF", err.Error())
+		assert.Equal(t, "domain.not_found", errors.CodeOf(err))
+		assert.Equal(t, http.StatusNotFound, errors.StatusOf(err))
+		assert.Equal(t, errors.KindNotFound, errors.KindOf(err))
+		assert.Equal(t, "example.com", errors.FieldsOf(err)["domain.id"])
+	})
+
+	t.Run("ProblemDetails", func(t *testing.T) {
+		resp := response(http.StatusTooManyRequests, "application/problem+json", `{
+			"type": "https://example.com/probs/rate-limited",
+			"title": "Too Many Requests",
+			"detail": "Rate limit of 100 requests per minute exceeded",
+			"code": "rate.exceeded",
+			"limit": 100
+		}`)
+		resp.Header.Set(errhttp.HeaderID, "abc123")
+
+		err := errhttp.DecodeResponse(resp)
+		require.Error(t, err)
+		assert.Equal(t, "Rate limit of 100 requests per minute exceeded", err.Error())
+		assert.Equal(t, "rate.exceeded", errors.CodeOf(err))
+		assert.Equal(t, "abc123", errors.IDOf(err))
+		assert.Equal(t, http.StatusTooManyRequests, errors.StatusOf(err))
+		assert.Equal(t, map[string]any{"limit": float64(100)}, errors.FieldsOf(err))
+	})
+
+	t.Run("falls back to the headers", func(t *testing.T) {
+		for _, resp := range []*http.Response{
+			response(http.StatusBadGateway, "text/html", "<html>bad gateway</html>"),
+			response(http.StatusBadGateway, "application/json", "{not json"),
+			response(http.StatusBadGateway, "application/json", `{"message": "unknown schema"}`),
+			response(http.StatusBadGateway, "", ""),
+		} {
+			err := errhttp.DecodeResponse(resp)
+			require.Error(t, err)
+			assert.Equal(t, "upstream returned '502 Bad Gateway'", err.Error())
+			assert.Equal(t, http.StatusBadGateway, errors.StatusOf(err))
+		}
+	})
+
+	t.Run("body is limited", func(t *testing.T) {
+		defer func(size int64) { errhttp.MaxBodySize = size }(errhttp.MaxBodySize)
+		errhttp.MaxBodySize = 10
+		body := `{"detail": "` + strings.Repeat("x", 100) + `"}`
+		err := errhttp.DecodeResponse(response(http.StatusBadRequest, "application/problem+json", body))
+		assert.Equal(t, "upstream returned '400 Bad Request'", err.Error())
+	})
+
+	t.Run("success", func(t *testing.T) {
+		assert.NoError(t, errhttp.DecodeResponse(response(http.StatusOK, "application/json", `{}`)))
+	})
+}
@@ -0,0 +1,135 @@
+// Package errhttp propagates error classification between services over HTTP
+// using response headers, such that an error returned by an upstream service
+// can be reconstructed by the client without parsing a bespoke payload.
+package errhttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/mailgun/errors"
+)
+
+// Headers used to propagate the error between services
+const (
+	HeaderCode   = "X-Mailgun-Error-Code"
+	HeaderKind   = "X-Mailgun-Error-Kind"
+	HeaderID     = "X-Mailgun-Error-Id"
+	HeaderFields = "X-Mailgun-Error-Fields"
+)
+
+// SetHeaders sets the X-Mailgun-Error-* headers from the code, kind and id attached to
+// err. Only the fields named in safeFields and permitted by errors.SetExportAllowlist()
+// are included, as all other fields might contain information which should not leave
+// the service. Fields are encoded in a single header as a URL query string, such that
+// the case of field names is preserved.
+//
+//	errhttp.SetHeaders(w.Header(), err, "domain.id", "account.id")
+//	w.WriteHeader(http.StatusNotFound)
+func SetHeaders(h http.Header, err error, safeFields ...string) {
+	if err == nil {
+		return
+	}
+	if code := errors.CodeOf(err); code != "" {
+		h.Set(HeaderCode, code)
+	}
+	if kind := errors.KindOf(err); kind != "" {
+		h.Set(HeaderKind, string(kind))
+	}
+	if id := errors.IDOf(err); id != "" {
+		h.Set(HeaderID, id)
+	}
+
+	all := errors.ToMap(err)
+	values := url.Values{}
+	for _, key := range safeFields {
+		if value, ok := all[key]; ok && errors.ExportAllowed(key) {
+			values.Set(key, fmt.Sprint(value))
+		}
+	}
+	if len(values) != 0 {
+		h.Set(HeaderFields, values.Encode())
+	}
+}
+
+// FromResponse reconstructs an error from the X-Mailgun-Error-* headers of the response.
+// Returns nil if the response status is not an error and no error headers are present.
+//
+//	resp, err := http.DefaultClient.Do(req)
+//	if err != nil {
+//		return err
+//	}
+//	if err := errhttp.FromResponse(resp); err != nil {
+//		return errors.Wrap(err, "while calling upstream")
+//	}
+func FromResponse(resp *http.Response) error {
+	return FromHeaders(resp.Header, resp.StatusCode)
+}
+
+// FromHeaders is identical to FromResponse but accepts the headers and status code directly.
+// The returned error carries the status, code, kind, id and fields found in the headers,
+// which can be retrieved using errors.StatusOf(), errors.CodeOf(), errors.KindOf() and
+// errors.IDOf(). If no kind is found, it is the Kind the status is mapped to by
+// errors.KindForStatus().
+func FromHeaders(h http.Header, status int) error {
+	env := fromHeaders(h, status)
+	if env == nil {
+		return nil
+	}
+	return env.ToError()
+}
+
+// fromHeaders returns the envelope described by the headers, nil if there is no error
+func fromHeaders(h http.Header, status int) *errors.Envelope {
+	code, kind, id := h.Get(HeaderCode), h.Get(HeaderKind), h.Get(HeaderID)
+	if status < 400 && code == "" && kind == "" && id == "" {
+		return nil
+	}
+
+	fields := errors.Fields{}
+	if encoded := h.Get(HeaderFields); encoded != "" {
+		// Malformed fields are ignored, as the classification is still useful
+		values, _ := url.ParseQuery(encoded)
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fields[key] = values.Get(key)
+		}
+	}
+
+	if kind == "" {
+		kind = string(errors.KindForStatus(status))
+	}
+
+	msg := fmt.Sprintf("upstream returned '%d %s'", status, http.StatusText(status))
+	if code != "" {
+		msg += fmt.Sprintf(" with code '%s'", code)
+	}
+	return &errors.Envelope{
+		Version: errors.EnvelopeVersion,
+		Message: msg,
+		Status:  status,
+		Code:    code,
+		Kind:    errors.Kind(kind),
+		ID:      id,
+		Fields:  fields,
+	}
+}
+
+// Status returns the HTTP status which should be returned to the client for err.
+// It is the status returned by errors.StatusOf(), which falls back to the status the
+// Kind of err is mapped to by errors.StatusForKind(), otherwise 500.
+//
+//	errhttp.SetHeaders(w.Header(), err)
+//	w.WriteHeader(errhttp.Status(err))
+func Status(err error) int {
+	if status := errors.StatusOf(err); status != 0 {
+		return status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,89 @@
+package errhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/errhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := errors.WrapOpts(io.EOF, "while fetching domain",
+			errors.WithCode("domain.not_found"),
+			errors.WithKind("not_found"),
+			errors.WithID("abc123"),
+			errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com", "password": "secret"}),
+		)
+		err = errors.Wrap(err, "while handling request")
+		errhttp.SetHeaders(w.Header(), err, "domain.id", "missing")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "domain.not_found", resp.Header.Get(errhttp.HeaderCode))
+	assert.Equal(t, "not_found", resp.Header.Get(errhttp.HeaderKind))
+	assert.Equal(t, "abc123", resp.Header.Get(errhttp.HeaderID))
+	assert.Equal(t, "domain.id=example.com", resp.Header.Get(errhttp.HeaderFields))
+
+	err = errhttp.FromResponse(resp)
+	require.Error(t, err)
+	assert.Equal(t, "upstream returned '404 Not Found' with code 'domain.not_found'", err.Error())
+
+	assert.Equal(t, http.StatusNotFound, errors.StatusOf(err))
+	assert.Equal(t, "domain.not_found", errors.CodeOf(err))
+	assert.Equal(t, errors.Kind("not_found"), errors.KindOf(err))
+	assert.Equal(t, "abc123", errors.IDOf(err))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "example.com", m["domain.id"])
+	assert.NotContains(t, m, "password")
+}
+
+func TestFromHeaders(t *testing.T) {
+	t.Run("no error", func(t *testing.T) {
+		assert.NoError(t, errhttp.FromHeaders(http.Header{}, http.StatusOK))
+	})
+
+	t.Run("error status without headers", func(t *testing.T) {
+		err := errhttp.FromHeaders(http.Header{}, http.StatusInternalServerError)
+		require.Error(t, err)
+		assert.Equal(t, "upstream returned '500 Internal Server Error'", err.Error())
+	})
+
+	t.Run("SetHeaders ignores nil error", func(t *testing.T) {
+		h := http.Header{}
+		errhttp.SetHeaders(h, nil)
+		assert.Empty(t, h)
+	})
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, errhttp.Status(errors.WrapOpts(io.EOF, "not found",
+		errors.WithKind(errors.KindNotFound))))
+	assert.Equal(t, http.StatusTeapot, errhttp.Status(errors.WrapOpts(io.EOF, "teapot",
+		errors.WithKind(errors.KindNotFound), errors.WithStatus(http.StatusTeapot))))
+	assert.Equal(t, http.StatusInternalServerError, errhttp.Status(io.EOF))
+
+	err := errhttp.FromHeaders(http.Header{}, http.StatusTooManyRequests)
+	assert.Equal(t, errors.KindRateLimited, errors.KindOf(err))
+}
+
+func TestSetHeadersExportAllowlist(t *testing.T) {
+	errors.SetExportAllowlist("domain.id")
+	defer errors.SetExportAllowlist()
+
+	h := http.Header{}
+	err := errors.WrapKV(io.EOF, "message", "domain.id", "example.com", "password", "secret")
+	errhttp.SetHeaders(h, err, "domain.id", "password")
+	assert.Equal(t, "domain.id=example.com", h.Get(errhttp.HeaderFields))
+}
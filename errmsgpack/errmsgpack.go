@@ -0,0 +1,37 @@
+// Package errmsgpack provides the msgpack encoding of errors.Envelope, a more compact
+// alternative to errors.ToJSON() for event payloads. It is a separate module such
+// that users of the errors package do not depend on the msgpack library.
+package errmsgpack
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mailgun/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ToMsgpack returns the msgpack encoding of the Envelope for err, with only the fields
+// permitted by errors.SetExportAllowlist(). The keys are the same as the JSON encoding
+// produced by errors.ToJSON().
+func ToMsgpack(err error) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(errors.ToEnvelope(err).Exportable()); err != nil {
+		return nil, fmt.Errorf("while encoding error envelope: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// FromMsgpack decodes an Envelope produced by ToMsgpack(). As with errors.ParseJSON(),
+// fields unknown to this version of the package are ignored.
+func FromMsgpack(b []byte) (*errors.Envelope, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	var env errors.Envelope
+	if err := dec.Decode(&env); err != nil {
+		return nil, fmt.Errorf("while decoding error envelope: %w", err)
+	}
+	return &env, nil
+}
@@ -0,0 +1,40 @@
+package errmsgpack_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/errmsgpack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgpack(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while fetching",
+		errors.WithCode("domain.not_found"),
+		errors.WithStatus(404),
+		errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com", "attempt": 3}),
+	)
+
+	b, mErr := errmsgpack.ToMsgpack(err)
+	require.NoError(t, mErr)
+	j, jErr := errors.ToJSON(err)
+	require.NoError(t, jErr)
+	assert.Less(t, len(b), len(j))
+
+	env, dErr := errmsgpack.FromMsgpack(b)
+	require.NoError(t, dErr)
+	assert.Equal(t, errors.EnvelopeVersion, env.Version)
+	assert.Equal(t, "while fetching: EOF", env.Message)
+	assert.Equal(t, "domain.not_found", env.Code)
+	assert.Equal(t, 404, env.Status)
+	assert.Equal(t, "example.com", env.Fields["domain.id"])
+	assert.EqualValues(t, 3, env.Fields["attempt"])
+	require.NotEmpty(t, env.Frames)
+	assert.Equal(t, "errmsgpack_test.TestMsgpack", env.Frames[0].Func)
+	assert.Equal(t, "while fetching: EOF", env.ToError().Error())
+
+	_, dErr = errmsgpack.FromMsgpack([]byte{0xc1})
+	assert.Error(t, dErr)
+}
@@ -4,12 +4,54 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mailgun/errors/callstack"
 )
 
 // NoMsg is a small indicator in the code that "" is intentional and there
 // is no message include with the Wrap()
 const NoMsg = ""
 
+// MaxMessageLength if greater than zero limits the length in bytes of the message
+// returned by Error() for errors created by this package, and of the messages exported
+// by ToMap(), ToEnvelope(), the Log functions and MessageOf() for any error. Longer
+// messages are truncated and end with TruncatedMarker, protecting log pipelines from
+// chains which include an entire response body in a message. It defaults to 0, which
+// does not limit the length.
+var MaxMessageLength = 0
+
+// TruncatedMarker is appended to messages truncated according to MaxMessageLength
+const TruncatedMarker = "...(truncated)"
+
+// limitMsg truncates msg according to MaxMessageLength without splitting a rune. A
+// message which was already truncated is returned as is, as wrappers which do not
+// add a message return the message of the error they wrap.
+func limitMsg(msg string) string {
+	limit := MaxMessageLength
+	if limit <= 0 || len(msg) <= limit {
+		return msg
+	}
+	if len(msg) <= limit+len(TruncatedMarker) && strings.HasSuffix(msg, TruncatedMarker) {
+		return msg
+	}
+	for limit > 0 && !utf8.RuneStart(msg[limit]) {
+		limit--
+	}
+	return msg[:limit] + TruncatedMarker
+}
+
+// MessageOf returns the message of err truncated according to MaxMessageLength.
+// It returns "" if err is nil.
+func MessageOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	return limitMsg(err.Error())
+}
+
 // Import all the standard errors functions as a convenience.
 
 // Is reports whether any error in err's chain matches target.
@@ -17,6 +59,34 @@ func Is(err, target error) bool {
 	return errors.Is(err, target)
 }
 
+// MatchWrapperType controls whether the wrapper types of this package match any other
+// error of the same wrapper type when compared using Is(). For example, with this
+// enabled two unrelated errors created using Wrap() are considered equal by Is(), which
+// causes false positives in matching logic. It defaults to true for compatibility with
+// code which depends on this behavior; new code should disable it and use IsWrapped()
+// and HasFieldsAttached() instead. It should only be modified once at startup.
+var MatchWrapperType = true
+
+// IsWrapped reports whether any error in err's chain, including the branches of
+// Join() and Errorf() with more than one %w, was wrapped using this package.
+func IsWrapped(err error) bool {
+	_, ok := find(err, func(e error) (bool, bool) {
+		_, ok := e.(wrapper)
+		return ok, ok
+	})
+	return ok
+}
+
+// HasFieldsAttached reports whether any error in err's chain has fields attached,
+// such as those attached using Fields.Wrap(), WithFieldsOpt() or AddFields().
+func HasFieldsAttached(err error) bool {
+	_, ok := find(err, func(e error) (bool, bool) {
+		f, ok := e.(HasFields)
+		return true, ok && len(f.HasFields()) != 0
+	})
+	return ok
+}
+
 // As finds the first error in err's chain that matches target, and if so, sets
 // target to that error value and returns true.
 func As(err error, target any) bool {
@@ -37,22 +107,76 @@ func Unwrap(err error) error {
 }
 
 // Errorf formats according to a format specifier and returns the string as a
-// value that satisfies error.
+// value that satisfies error. A stack trace is attached at the point Errorf is called.
 //
 // If the format specifier includes a %w verb with an error operand,
-// the returned error will implement an Unwrap method returning the operand. It is
-// invalid to include more than one %w verb or to supply it with an operand
-// that does not implement the error interface. The %w verb is otherwise
-// a synonym for %v.
+// the returned error will implement an Unwrap method returning the operand.
+// If there is more than one %w verb, the returned error will implement an
+// Unwrap method returning a []error containing all the %w operands in the
+// order they appear in the arguments. It is invalid to supply the %w verb
+// with an operand that does not implement the error interface. The %w verb
+// is otherwise a synonym for %v.
 func Errorf(format string, a ...any) error {
-	return fmt.Errorf(format, a...)
+	err := fmt.Errorf(format, a...)
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	case interface{ Unwrap() error }:
+		if w := e.Unwrap(); w != nil {
+			wrapped = []error{w}
+		}
+	}
+	switch len(wrapped) {
+	case 0:
+		// A %w verb whose operand is nil wraps nothing
+	case 1:
+		return observeWrap(&formattedError{
+			Trace:   callstack.Capture(1),
+			wrapped: wrapped[0],
+			msg:     err.Error(),
+		})
+	default:
+		return observeWrap(&formattedErrors{
+			Trace:   callstack.Capture(1),
+			wrapped: wrapped,
+			msg:     err.Error(),
+			own:     ownMessage(format, a...),
+		})
+	}
+	return observeWrap(&formattedMsg{
+		Trace: callstack.Capture(1),
+		msg:   err.Error(),
+	})
+}
+
+// TraversalOrder is the order in which LastOpt() visits the errors of a tree, such
+// as those created by Join() or Errorf() with more than one %w verb.
+type TraversalOrder int
+
+const (
+	// PreOrder visits each error before its children, and the children from first to
+	// last. For a chain without joined errors, this is the order of Unwrap().
+	PreOrder TraversalOrder = iota
+	// PostOrder visits the children of each error from first to last before the error
+	// itself. As such the last match is the one nearest to the root of the tree.
+	PostOrder
+)
+
+// LastOptions modifies the behavior of LastOpt()
+type LastOptions struct {
+	// Order is the order in which the tree of errors is visited, defaults to PreOrder
+	Order TraversalOrder
 }
 
-// Last finds the last error in err's chain that matches target, and if one is found, sets
+// Last finds the last error in err's tree that matches target, and if one is found, sets
 // target to that error value and returns true. Otherwise, it returns false.
 //
-// The chain consists of err itself followed by the sequence of errors obtained by
-// repeatedly calling Unwrap.
+// The tree consists of err itself followed by the errors obtained by repeatedly calling
+// its Unwrap() error or Unwrap() []error method. Last visits the tree depth first in
+// PreOrder, as such for a chain without joined errors the last match is the one closest
+// to the cause, and for a tree it is the deepest match in the last branch with a match.
+// Use LastOpt() to choose a different order.
 //
 // An error matches target if the error's concrete value is assignable to the value
 // pointed to by target, or if the error has a method `As(any) bool` such that
@@ -68,6 +192,48 @@ func Errorf(format string, a ...any) error {
 // unless you absolutely need Last() to retrieve the last error in the error chain
 // that matches the target.
 func Last(err error, target any) bool {
+	return LastOpt(err, target, LastOptions{})
+}
+
+// LastOpt is identical to Last() but allows the caller to choose the order in which
+// the tree is visited.
+//
+//	errors.LastOpt(err, &target, errors.LastOptions{Order: errors.PostOrder})
+func LastOpt(err error, target any, opts LastOptions) bool {
+	val, targetType := lastTarget(target)
+	var found error
+	walk(err, opts.Order, func(e error) {
+		if matches(e, target, targetType) {
+			found = e
+		}
+	})
+	if found != nil {
+		val.Elem().Set(reflect.ValueOf(found))
+		return true
+	}
+	return false
+}
+
+// lastInChain is identical to Last() but only follows Unwrap() error, for finding
+// details such as the stack trace where errors with more than one child, like
+// Fields.WrapAll(), choose which of their children to report.
+func lastInChain(err error, target any) bool {
+	val, targetType := lastTarget(target)
+	var found error
+	for err != nil {
+		if matches(err, target, targetType) {
+			found = err
+		}
+		err = Unwrap(err)
+	}
+	if found != nil {
+		val.Elem().Set(reflect.ValueOf(found))
+		return true
+	}
+	return false
+}
+
+func lastTarget(target any) (reflect.Value, reflect.Type) {
 	if target == nil {
 		panic("errors: target cannot be nil")
 	}
@@ -80,21 +246,120 @@ func Last(err error, target any) bool {
 	if targetType.Kind() != reflect.Interface && !targetType.Implements(errorType) {
 		panic("errors: *target must be interface or implement error")
 	}
-	var found error
-	for err != nil {
-		if reflect.TypeOf(err).AssignableTo(targetType) {
-			found = err
+	return val, targetType
+}
+
+func matches(err error, target any, targetType reflect.Type) bool {
+	if reflect.TypeOf(err).AssignableTo(targetType) {
+		return true
+	}
+	x, ok := err.(interface{ As(any) bool })
+	return ok && x.As(target)
+}
+
+// WalkTree calls fn for each error in err's tree depth first, visiting each error before
+// its children, and the children from first to last. The path is the index of the child
+// taken at each level to reach the error, where the child of Unwrap() error has index 0,
+// such that len(path) is the depth of the error and err itself has an empty path. If fn
+// returns false the walk stops.
+//
+//	errors.WalkTree(err, func(e error, path []int) bool {
+//		fmt.Printf("%s%v: %s\n", strings.Repeat("  ", len(path)), path, e)
+//		return true
+//	})
+func WalkTree(err error, fn func(e error, path []int) bool) {
+	walkTree(err, nil, fn)
+}
+
+func walkTree(err error, path []int, fn func(error, []int) bool) bool {
+	if err == nil {
+		return true
+	}
+	if !fn(err, append([]int(nil), path...)) {
+		return false
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return walkTree(e.Unwrap(), append(path, 0), fn)
+	case interface{ Unwrap() []error }:
+		for i, child := range e.Unwrap() {
+			if !walkTree(child, append(path, i), fn) {
+				return false
+			}
 		}
-		if x, ok := err.(interface{ As(any) bool }); ok && x.As(target) {
-			found = err
+	}
+	return true
+}
+
+// walk calls visit for each error in err's tree in the order provided
+func walk(err error, order TraversalOrder, visit func(error)) {
+	if err == nil {
+		return
+	}
+	if order == PreOrder {
+		visit(err)
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		walk(e.Unwrap(), order, visit)
+	case interface{ Unwrap() []error }:
+		for _, child := range e.Unwrap() {
+			walk(child, order, visit)
 		}
-		err = Unwrap(err)
 	}
-	if found != nil {
-		val.Elem().Set(reflect.ValueOf(found))
-		return true
+	if order == PostOrder {
+		visit(err)
 	}
-	return false
+}
+
+// Caller returns the frame where the error occurred, which is the innermost frame of
+// the last stack trace found in err's chain. If no stack trace is found, it returns
+// false.
+//
+//	if caller, ok := errors.Caller(err); ok {
+//		fmt.Printf("error occurred at %s\n", caller)
+//	}
+func Caller(err error) (callstack.FrameInfo, bool) {
+	trace, ok := StackOf(err)
+	if !ok {
+		return callstack.FrameInfo{}, false
+	}
+	return callstack.GetLastFrame(trace), true
+}
+
+// StackOf returns the last stack trace found in err's chain, which is the one closest
+// to where the error occurred. If no stack trace is found, it returns false.
+//
+//	if trace, ok := errors.StackOf(err); ok {
+//		fmt.Printf("%+v", trace)
+//	}
+func StackOf(err error) (callstack.StackTrace, bool) {
+	var stack callstack.HasStackTrace
+	if !lastInChain(err, &stack) {
+		return nil, false
+	}
+	return stack.StackTrace(), true
+}
+
+// HasCreatedAt is implemented by errors which record when they were created
+type HasCreatedAt interface {
+	CreatedAt() time.Time
+}
+
+// NowFunc returns the current time and is used to record when errors are created.
+// Tests may replace it to produce deterministic timestamps.
+var NowFunc = time.Now
+
+// CreatedAt returns the time the last error in err's chain which records a creation
+// time was created. As the last error is closest to where the failure occurred, this
+// can be used to compute the time between a failure and when it was logged, or to
+// order errors collected asynchronously. If no creation time is found, it returns false.
+func CreatedAt(err error) (time.Time, bool) {
+	var c HasCreatedAt
+	if !lastInChain(err, &c) {
+		return time.Time{}, false
+	}
+	return c.CreatedAt(), true
 }
 
 // Join returns an error that wraps the given errors.
@@ -109,4 +374,112 @@ func Join(errs ...error) error {
 	return errors.Join(errs...)
 }
 
+// EqualChains reports whether the error chains of a and b are structurally identical.
+// Each error in the chain must have the same type, message and fields. Stack traces
+// are ignored, so two chains created by the same code at different locations are
+// considered equal. This is intended for tests asserting that a refactor did not
+// change the semantics of the errors returned.
+func EqualChains(a, b error) bool {
+	for {
+		if a == nil || b == nil {
+			return a == b
+		}
+		if reflect.TypeOf(a) != reflect.TypeOf(b) || a.Error() != b.Error() {
+			return false
+		}
+		if !equalFields(ownFields(a), ownFields(b)) {
+			return false
+		}
+		if x, ok := a.(interface{ Unwrap() []error }); ok {
+			left, right := x.Unwrap(), b.(interface{ Unwrap() []error }).Unwrap()
+			if len(left) != len(right) {
+				return false
+			}
+			for i := range left {
+				if !EqualChains(left[i], right[i]) {
+					return false
+				}
+			}
+			return true
+		}
+		a, b = Unwrap(a), Unwrap(b)
+	}
+}
+
+// wrapper is implemented by each of the error types of this package, such that the
+// operations over a chain such as Clone() and StripStack() do not list every type.
+type wrapper interface {
+	error
+	// ownFields returns only the fields attached directly to the error, not
+	// the fields collected from the rest of the chain.
+	ownFields() map[string]any
+	// message returns only the message added by the error, not the messages
+	// of the rest of the chain, or NoMsg if it adds none.
+	message() string
+	// clone returns a copy of the error and the chain it wraps, see Clone()
+	clone() error
+	// stripStack returns a copy of the error and the chain it wraps without
+	// stack traces, see StripStack()
+	stripStack() error
+}
+
+var (
+	_ wrapper = (*wrappedError)(nil)
+	_ wrapper = (*formattedError)(nil)
+	_ wrapper = (*formattedErrors)(nil)
+	_ wrapper = (*formattedMsg)(nil)
+	_ wrapper = (*fields)(nil)
+	_ wrapper = (*fieldsJoin)(nil)
+	_ wrapper = (*stack)(nil)
+	_ wrapper = (*annotation)(nil)
+	_ wrapper = (*annotated)(nil)
+	_ wrapper = (*annotatedStack)(nil)
+	_ wrapper = (*backoff)(nil)
+	_ wrapper = (*breadcrumbs)(nil)
+	_ wrapper = (*decodedError)(nil)
+	_ wrapper = (*PanicError)(nil)
+)
+
+// Clone returns a copy of err where each of the wrapper types from this package in
+// the chain is copied, including the maps of attached fields. The copy can be safely
+// augmented without racing against another goroutine which is reading the original.
+//
+// Errors in the chain which are not from this package cannot be copied, as such
+// cloning stops at the first foreign error, and it is shared by both chains. Stack
+// traces are immutable and are also shared.
+func Clone(err error) error {
+	if w, ok := err.(wrapper); ok {
+		return w.clone()
+	}
+	return err
+}
+
+// cloneAll returns a copy of errs where each error is cloned
+func cloneAll(errs []error) []error {
+	result := make([]error, len(errs))
+	for i, err := range errs {
+		result[i] = Clone(err)
+	}
+	return result
+}
+
+// ownFields returns only the fields attached directly to err, not
+// the fields collected from the rest of the chain.
+func ownFields(err error) map[string]any {
+	switch e := err.(type) {
+	case wrapper:
+		return e.ownFields()
+	case HasFields:
+		return e.HasFields()
+	}
+	return nil
+}
+
+func equalFields(a, b map[string]any) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
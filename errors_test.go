@@ -1,11 +1,18 @@
 package errors_test
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mailgun/errors"
 	"github.com/mailgun/errors/callstack"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type ErrTest struct {
@@ -44,7 +51,7 @@ func TestLast(t *testing.T) {
 	err = errors.Wrap(err, "last")
 	err = errors.Wrap(err, "second")
 	err = errors.Wrap(err, "first")
-	err = errors.Errorf("wrapped: %w", err)
+	err = fmt.Errorf("wrapped: %w", err)
 
 	// errors.As() returns the "first" error in the chain with a stack trace
 	var first callstack.HasStackTrace
@@ -60,3 +67,275 @@ func TestLast(t *testing.T) {
 	assert.False(t, errors.Last(errors.New("no stack"), &last))
 	assert.Equal(t, "last: bottom", last.(error).Error())
 }
+
+func TestLastTree(t *testing.T) {
+	left := errors.Wrap(errors.Wrap(io.EOF, "left inner"), "left outer")
+	right := errors.Wrap(errors.Wrap(io.EOF, "right inner"), "right outer")
+	err := errors.Wrap(errors.Join(left, right), "root")
+
+	// PreOrder returns the deepest match in the last branch
+	var last callstack.HasStackTrace
+	assert.True(t, errors.Last(err, &last))
+	assert.Equal(t, "right inner: EOF", last.(error).Error())
+
+	// PostOrder returns the match nearest to the root
+	assert.True(t, errors.LastOpt(err, &last, errors.LastOptions{Order: errors.PostOrder}))
+	assert.Equal(t, err, last)
+
+	err = errors.Join(left, right)
+	assert.True(t, errors.LastOpt(err, &last, errors.LastOptions{Order: errors.PostOrder}))
+	assert.Equal(t, right, last)
+
+	// Matches in branches other than the last are found
+	err = errors.Join(left, io.ErrUnexpectedEOF)
+	assert.True(t, errors.Last(err, &last))
+	assert.Equal(t, "left inner: EOF", last.(error).Error())
+}
+
+func TestWalkTree(t *testing.T) {
+	first := errors.Fields{"item": 1}.Wrap(io.EOF, "item 1")
+	second := errors.Join(io.ErrUnexpectedEOF, io.ErrClosedPipe)
+	err := errors.Wrap(errors.Join(first, second), "batch")
+
+	var visited []string
+	errors.WalkTree(err, func(e error, path []int) bool {
+		visited = append(visited, fmt.Sprintf("%v %T", path, e))
+		return true
+	})
+	assert.Equal(t, []string{
+		"[] *errors.wrappedError",
+		"[0] *errors.joinError",
+		"[0 0] *errors.fields",
+		"[0 0 0] *errors.errorString",
+		"[0 1] *errors.joinError",
+		"[0 1 0] *errors.errorString",
+		"[0 1 1] *errors.errorString",
+	}, visited)
+
+	// Returning false stops the walk
+	var paths [][]int
+	errors.WalkTree(err, func(e error, path []int) bool {
+		paths = append(paths, path)
+		return e != first
+	})
+	assert.Equal(t, [][]int{nil, {0}, {0, 0}}, paths)
+
+	errors.WalkTree(nil, func(error, []int) bool {
+		t.Fatal("should not be called for a nil error")
+		return true
+	})
+}
+
+func TestEqualChains(t *testing.T) {
+	create := func(value string) error {
+		err := errors.New("bottom")
+		err = errors.Fields{"key1": value}.Wrap(err, "fields")
+		err = errors.Stack(err)
+		return errors.Wrap(err, "top")
+	}
+
+	assert.True(t, errors.EqualChains(nil, nil))
+	assert.True(t, errors.EqualChains(create("value1"), create("value1")))
+	assert.False(t, errors.EqualChains(create("value1"), create("value2")))
+	assert.False(t, errors.EqualChains(create("value1"), nil))
+	assert.False(t, errors.EqualChains(
+		errors.Wrap(&ErrTest{Msg: "error"}, "message"),
+		errors.Wrap(errors.New("error"), "message"),
+	))
+	assert.False(t, errors.EqualChains(
+		errors.Wrap(errors.New("error"), "message"),
+		errors.Stack(errors.New("message: error")),
+	))
+	assert.True(t, errors.EqualChains(
+		errors.Join(create("value1"), &ErrTest{Msg: "error"}),
+		errors.Join(create("value1"), &ErrTest{Msg: "error"}),
+	))
+	assert.False(t, errors.EqualChains(
+		errors.Join(create("value1"), &ErrTest{Msg: "error"}),
+		errors.Join(create("value2"), &ErrTest{Msg: "error"}),
+	))
+}
+
+func TestClone(t *testing.T) {
+	f := errors.Fields{"key1": "value1"}
+	err := f.Wrap(io.EOF, "fields")
+	err = errors.Stack(err)
+	err = errors.Wrap(err, "top")
+
+	c := errors.Clone(err)
+	assert.NotSame(t, err, c)
+	assert.True(t, errors.EqualChains(err, c))
+	assert.True(t, errors.Is(c, io.EOF))
+	assert.Equal(t, errors.ToMap(err), errors.ToMap(c))
+
+	// Mutating the original fields should not affect the clone
+	f["key2"] = "value2"
+	assert.Equal(t, "value2", errors.ToMap(err)["key2"])
+	assert.NotContains(t, errors.ToMap(c), "key2")
+
+	assert.Nil(t, errors.Clone(nil))
+	assert.Equal(t, io.EOF, errors.Clone(io.EOF))
+}
+
+func TestCaller(t *testing.T) {
+	err := errors.Wrap(io.EOF, "last")
+	err = fmt.Errorf("wrapped: %w", err)
+	err = errors.Wrap(err, "first")
+
+	caller, ok := errors.Caller(err)
+	assert.True(t, ok)
+	assert.Equal(t, "errors_test.TestCaller", caller.Func)
+	assert.Equal(t, errors.ToMap(err)["excLineNum"], caller.LineNo)
+	assert.Regexp(t, ".*/errors_test.go", caller.File)
+
+	_, ok = errors.Caller(io.EOF)
+	assert.False(t, ok)
+	_, ok = errors.Caller(nil)
+	assert.False(t, ok)
+}
+
+func TestCreatedAt(t *testing.T) {
+	now := time.Date(2023, 1, 26, 10, 37, 48, 0, time.UTC)
+	errors.NowFunc = func() time.Time { return now }
+	defer func() { errors.NowFunc = time.Now }()
+
+	err := errors.Wrap(io.EOF, "last")
+	now = now.Add(time.Second)
+	err = errors.Fields{"key1": "value1"}.Wrap(err, "first")
+
+	created, ok := errors.CreatedAt(err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2023, 1, 26, 10, 37, 48, 0, time.UTC), created)
+
+	created, ok = errors.CreatedAt(errors.WrapFields(io.EOF, errors.Fields{}, "fields"))
+	assert.True(t, ok)
+	assert.Equal(t, now, created)
+
+	_, ok = errors.CreatedAt(io.EOF)
+	assert.False(t, ok)
+}
+
+func TestMaxMessageLength(t *testing.T) {
+	errors.MaxMessageLength = 16
+	defer func() { errors.MaxMessageLength = 0 }()
+
+	body := errors.New(strings.Repeat("<html>", 100))
+	err := errors.Wrap(body, "while fetching")
+	assert.Equal(t, "while fetching: "+errors.TruncatedMarker, err.Error())
+	// Errors from other packages are limited when exported
+	assert.Equal(t, strings.Repeat("<html>", 100), body.Error())
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, errors.MessageOf(body))
+	assert.Equal(t, "while fetching: "+errors.TruncatedMarker, errors.MessageOf(err))
+	assert.Equal(t, "while fetching: "+errors.TruncatedMarker, fmt.Sprintf("%v", err))
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, fmt.Sprint(errors.Errorf("%w", body)))
+	assert.Equal(t, "short: EOF", errors.MessageOf(errors.Wrap(io.EOF, "short")))
+	assert.Equal(t, "", errors.MessageOf(nil))
+
+	// Wrappers without a message of their own are limited too
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, fmt.Sprint(errors.Stack(body)))
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, fmt.Sprint(errors.WithBackoff(body, time.Second, 1)))
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, errors.Stack(body).Error())
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, errors.WithBackoff(body, time.Second, 1).Error())
+	assert.Equal(t, "<html><html><htm"+errors.TruncatedMarker, errors.Fields{}.WrapAll(errors.NoMsg, body).Error())
+
+	// Wrapping a truncated error truncates the composed message once
+	assert.Equal(t, "outer: while fet"+errors.TruncatedMarker, errors.Wrap(err, "outer").Error())
+
+	// The limit applies once to each exported message, such that the messages of the chain do not repeat
+	m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeMessages: true})
+	assert.Equal(t, "while fetching: "+errors.TruncatedMarker, m["excValue"])
+	assert.Equal(t, []string{"while fetching", "<html><html><htm" + errors.TruncatedMarker}, m["excMessages"])
+	assert.Equal(t, "while fetching: "+errors.TruncatedMarker, errors.ToEnvelope(err).Message)
+
+	// Multibyte runes are not split
+	assert.Equal(t, "aééééééé"+errors.TruncatedMarker, errors.MessageOf(errors.Wrap(errors.New("a"+strings.Repeat("é", 20)), errors.NoMsg)))
+}
+
+func TestStackOf(t *testing.T) {
+	trace, ok := errors.StackOf(errors.Wrap(errors.Stack(io.EOF), "outer"))
+	assert.True(t, ok)
+	assert.Equal(t, "errors_test.TestStackOf", callstack.GetLastFrame(trace).Func)
+
+	_, ok = errors.StackOf(io.EOF)
+	assert.False(t, ok)
+	_, ok = errors.StackOf(nil)
+	assert.False(t, ok)
+}
+
+func TestMatchWrapperType(t *testing.T) {
+	errA := errors.Wrap(io.EOF, "first")
+	errB := errors.Wrap(io.ErrUnexpectedEOF, "second")
+	assert.True(t, errors.Is(errA, errB))
+
+	errors.MatchWrapperType = false
+	defer func() { errors.MatchWrapperType = true }()
+
+	assert.False(t, errors.Is(errA, errB))
+	assert.True(t, errors.Is(errA, io.EOF))
+	assert.True(t, errors.Is(errA, errA))
+}
+
+func TestIsWrapped(t *testing.T) {
+	assert.False(t, errors.IsWrapped(nil))
+	assert.False(t, errors.IsWrapped(io.EOF))
+	assert.False(t, errors.IsWrapped(fmt.Errorf("wrapped: %w", io.EOF)))
+	assert.True(t, errors.IsWrapped(fmt.Errorf("wrapped: %w", errors.Stack(io.EOF))))
+	assert.True(t, errors.IsWrapped(errors.Join(io.EOF, errors.Annotate(io.EOF, "annotated"))))
+}
+
+func TestHasFieldsAttached(t *testing.T) {
+	assert.False(t, errors.HasFieldsAttached(io.EOF))
+	assert.False(t, errors.HasFieldsAttached(errors.Wrap(io.EOF, "no fields")))
+	assert.True(t, errors.HasFieldsAttached(errors.Fields{"key": "value"}.Wrap(io.EOF, "fields")))
+	assert.True(t, errors.HasFieldsAttached(errors.Join(io.EOF, errors.WrapKV(io.EOF, "kv", "key", "value"))))
+}
+
+// Ensure every wrapper conforms to the old style Causer interface used by
+// `github.com/pkg/errors.Cause()`, and reports the same cause as Unwrap()
+func TestWrappersCause(t *testing.T) {
+	type causer interface {
+		Cause() error
+	}
+	cause := &ErrTest{Msg: "query error"}
+
+	var panicked error
+	func() {
+		defer func() { panicked, _ = recover().(error) }()
+		errors.Must(0, cause)
+	}()
+
+	for name, err := range map[string]error{
+		"Wrap":            errors.Wrap(cause, "message"),
+		"WrapLazyf":       errors.WrapLazyf(cause, "message %d", func() []any { return []any{1} }),
+		"Errorf":          errors.Errorf("message: %w", cause),
+		"Annotate":        errors.Annotate(cause, "message"),
+		"Fields.Wrap":     errors.Fields{"key": "value"}.Wrap(cause, "message"),
+		"WrapOpts":        errors.WrapOpts(cause, "message", errors.WithCode("code")),
+		"WrapOptsNoStack": errors.WrapOpts(cause, "message", errors.NoStack()),
+		"Stack":           errors.Stack(cause),
+		"WithBackoff":     errors.WithBackoff(cause, time.Second, 1),
+		"WithBreadcrumb":  errors.WithBreadcrumb(cause, "message"),
+		"Must":            panicked,
+		"WrapFn":          errors.WrapFn("message", func() error { return cause }),
+		"WrapCtx":         errors.WrapCtx(context.Background(), cause, "message"),
+		"WithRequest":     errors.WithRequest(cause, httptest.NewRequest("GET", "/", nil)),
+		"Set":             errors.Set(cause, errors.NewKey[string]("key"), "value"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, ok := err.(causer)
+			require.True(t, ok, "%T does not implement Cause()", err)
+			assert.Equal(t, errors.Unwrap(err), c.Cause())
+			assert.Equal(t, error(cause), pkgErrorCause(err))
+		})
+	}
+
+	// Errors which join more than one error follow the first of them
+	joined := errors.Fields{"key": "value"}.WrapAll("message", cause, io.EOF)
+	assert.Equal(t, error(cause), pkgErrorCause(errors.Wrap(joined, "message")))
+
+	// Errorf without %w has nothing to unwrap, so it is its own cause
+	err := errors.Errorf("message: %v", cause)
+	_, ok := err.(causer)
+	assert.False(t, ok, "%T should not implement Cause()", err)
+	assert.Equal(t, err, pkgErrorCause(err))
+}
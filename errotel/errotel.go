@@ -0,0 +1,199 @@
+// Package errotel exports errors to OpenTelemetry, such that error events flow
+// through the OTLP pipeline alongside traces and can be recorded by instrumentation.
+// Errors are described using the exception semantic conventions along with the
+// fields attached to the error.
+package errotel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mailgun/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the records emitted by an Exporter
+const ScopeName = "github.com/mailgun/errors/errotel"
+
+// Exporter emits errors as OpenTelemetry log records
+type Exporter struct {
+	logger log.Logger
+}
+
+// NewExporter returns an Exporter which emits records using a logger from provider
+//
+//	exporter := errotel.NewExporter(global.GetLoggerProvider())
+//	exporter.Emit(ctx, err)
+func NewExporter(provider log.LoggerProvider) *Exporter {
+	return &Exporter{logger: provider.Logger(ScopeName)}
+}
+
+// Emit emits err as a log record whose body is the error message, with the exception
+// attributes and the fields attached to err. As with errors.Log(), the severity is the
+// level chosen by errors.SeverityOf(), such that errors with an HTTP status in the 4xx
+// range are emitted as warnings. The timestamp is the time err was created (see
+// errors.CreatedAt()), otherwise errors.NowFunc(). If err is nil, nothing is emitted.
+func (e *Exporter) Emit(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	var r log.Record
+	now := errors.NowFunc()
+	created, ok := errors.CreatedAt(err)
+	if !ok {
+		created = now
+	}
+	r.SetTimestamp(created)
+	r.SetObservedTimestamp(now)
+	level := errors.SeverityOf(err)
+	r.SetSeverity(severity(level))
+	r.SetSeverityText(level.String())
+	if !e.logger.Enabled(ctx, r) {
+		return
+	}
+	r.SetBody(log.StringValue(errors.MessageOf(err)))
+	r.AddAttributes(logAttrs(errors.ToEnvelope(err))...)
+	e.logger.Emit(ctx, r)
+}
+
+// severity returns the OpenTelemetry severity of the slog level. The slog levels are
+// four apart, as are the OpenTelemetry severities, such that slog.LevelInfo maps to
+// log.SeverityInfo and a level such as slog.LevelError+2 maps to log.SeverityError3.
+func severity(level slog.Level) log.Severity {
+	s := int(level) + int(log.SeverityInfo)
+	if s < int(log.SeverityTrace1) {
+		return log.SeverityTrace1
+	}
+	if s > int(log.SeverityFatal4) {
+		return log.SeverityFatal4
+	}
+	return log.Severity(s)
+}
+
+// LogAttrs returns the exception attributes and the fields attached to err as
+// attributes for a log record.
+func LogAttrs(err error) []log.KeyValue {
+	if err == nil {
+		return nil
+	}
+	return logAttrs(errors.ToEnvelope(err))
+}
+
+func logAttrs(env *errors.Envelope) []log.KeyValue {
+	attrs := []log.KeyValue{
+		log.String(string(semconv.ExceptionTypeKey), env.Type),
+		log.String(string(semconv.ExceptionMessageKey), env.Message),
+	}
+	if len(env.Frames) != 0 {
+		attrs = append(attrs, log.String(string(semconv.ExceptionStacktraceKey), stacktrace(env)))
+	}
+	for key, value := range env.Fields {
+		attrs = append(attrs, log.KeyValue{Key: key, Value: logValue(value)})
+	}
+	return attrs
+}
+
+// Attributes returns the exception attributes and the fields attached to err, for use
+// by instrumentation which records errors on spans or metrics.
+//
+//	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(errotel.Attributes(err)...))
+func Attributes(err error) []attribute.KeyValue {
+	if err == nil {
+		return nil
+	}
+	env := errors.ToEnvelope(err)
+	attrs := []attribute.KeyValue{
+		semconv.ExceptionType(env.Type),
+		semconv.ExceptionMessage(env.Message),
+	}
+	if len(env.Frames) != 0 {
+		attrs = append(attrs, semconv.ExceptionStacktrace(stacktrace(env)))
+	}
+	for key, value := range env.Fields {
+		attrs = append(attrs, attributeValue(key, value))
+	}
+	return attrs
+}
+
+// Exemplar label names, following the OpenMetrics convention used by Grafana to link
+// an exemplar to its trace.
+const (
+	ExemplarTraceID = "trace_id"
+	ExemplarSpanID  = "span_id"
+)
+
+// Exemplar returns the labels of an exemplar linking a sample to the span recorded in
+// ctx, such that a spike on an error counter can be followed to an offending trace. The
+// result has the same underlying type as prometheus.Labels. If ctx has no valid span,
+// Exemplar returns nil, as a sample without a trace is not worth linking.
+//
+//	errorsTotal.(prometheus.ExemplarAdder).AddWithExemplar(1, errotel.Exemplar(ctx))
+func Exemplar(ctx context.Context) map[string]string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return map[string]string{
+		ExemplarTraceID: sc.TraceID().String(),
+		ExemplarSpanID:  sc.SpanID().String(),
+	}
+}
+
+// stacktrace formats the frames of the envelope in the format used by Go for panics
+func stacktrace(env *errors.Envelope) string {
+	var b strings.Builder
+	for _, f := range env.Frames {
+		_, _ = fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Func, f.File, f.Line)
+	}
+	return b.String()
+}
+
+func logValue(value any) log.Value {
+	switch v := value.(type) {
+	case string:
+		return log.StringValue(v)
+	case bool:
+		return log.BoolValue(v)
+	case int:
+		return log.IntValue(v)
+	case int64:
+		return log.Int64Value(v)
+	case float64:
+		return log.Float64Value(v)
+	case []byte:
+		return log.BytesValue(v)
+	case time.Duration:
+		return log.StringValue(v.String())
+	case time.Time:
+		return log.StringValue(v.Format(time.RFC3339Nano))
+	}
+	return log.StringValue(fmt.Sprint(value))
+}
+
+func attributeValue(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case time.Duration:
+		return attribute.String(key, v.String())
+	case time.Time:
+		return attribute.String(key, v.Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		return attribute.Stringer(key, v)
+	}
+	return attribute.String(key, fmt.Sprint(value))
+}
@@ -0,0 +1,154 @@
+package errotel_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/errotel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/logtest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func attrsOf(r log.Record) map[string]log.Value {
+	result := map[string]log.Value{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		result[kv.Key] = kv.Value
+		return true
+	})
+	return result
+}
+
+func TestExporter(t *testing.T) {
+	rec := logtest.NewRecorder()
+	exporter := errotel.NewExporter(rec)
+
+	err := errors.Fields{
+		"domain":  "example.com",
+		"attempt": 2,
+		"elapsed": time.Second,
+	}.Wrap(io.EOF, "while fetching")
+	exporter.Emit(context.Background(), err)
+	exporter.Emit(context.Background(), errors.WrapOpts(io.EOF, "bad request", errors.WithStatus(400)))
+	exporter.Emit(context.Background(), nil)
+
+	result := rec.Result()
+	require.Len(t, result, 1)
+	assert.Equal(t, errotel.ScopeName, result[0].Name)
+	require.Len(t, result[0].Records, 2)
+
+	r := result[0].Records[0]
+	assert.Equal(t, "while fetching: EOF", r.Body().AsString())
+	assert.Equal(t, log.SeverityError, r.Severity())
+	created, _ := errors.CreatedAt(err)
+	assert.Equal(t, created, r.Timestamp())
+
+	attrs := attrsOf(r)
+	assert.Equal(t, "*errors.errorString", attrs["exception.type"].AsString())
+	assert.Equal(t, "while fetching: EOF", attrs["exception.message"].AsString())
+	assert.Contains(t, attrs["exception.stacktrace"].AsString(), "errotel_test.TestExporter\n\t")
+	assert.Equal(t, "example.com", attrs["domain"].AsString())
+	assert.Equal(t, int64(2), attrs["attempt"].AsInt64())
+	assert.Equal(t, "1s", attrs["elapsed"].AsString())
+
+	assert.Equal(t, log.SeverityWarn, result[0].Records[1].Severity())
+	assert.Equal(t, "WARN", result[0].Records[1].SeverityText())
+}
+
+type severityError struct {
+	error
+	level slog.Level
+}
+
+func (e *severityError) Severity() slog.Level { return e.level }
+
+func TestExporterSeverity(t *testing.T) {
+	for _, test := range []struct {
+		level    slog.Level
+		severity log.Severity
+		text     string
+	}{
+		{level: slog.LevelDebug, severity: log.SeverityDebug, text: "DEBUG"},
+		{level: slog.LevelInfo, severity: log.SeverityInfo, text: "INFO"},
+		{level: slog.LevelWarn, severity: log.SeverityWarn, text: "WARN"},
+		{level: slog.LevelError, severity: log.SeverityError, text: "ERROR"},
+		{level: slog.LevelError + 2, severity: log.SeverityError3, text: "ERROR+2"},
+		{level: slog.LevelError + 100, severity: log.SeverityFatal4, text: "ERROR+100"},
+	} {
+		rec := logtest.NewRecorder()
+		err := errors.Wrap(&severityError{error: io.EOF, level: test.level}, "while fetching")
+		errotel.NewExporter(rec).Emit(context.Background(), err)
+
+		result := rec.Result()
+		require.Len(t, result, 1)
+		require.Len(t, result[0].Records, 1)
+		assert.Equal(t, test.severity, result[0].Records[0].Severity(), test.text)
+		assert.Equal(t, test.text, result[0].Records[0].SeverityText())
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	err := errors.Fields{"domain": "example.com", "attempt": 2, "elapsed": time.Second}.Wrap(io.EOF, "while fetching")
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range errotel.Attributes(err) {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "*errors.errorString", attrs[semconv.ExceptionTypeKey].AsString())
+	assert.Equal(t, "while fetching: EOF", attrs[semconv.ExceptionMessageKey].AsString())
+	assert.Contains(t, attrs[semconv.ExceptionStacktraceKey].AsString(), "errotel_test.TestAttributes\n\t")
+	assert.Equal(t, "example.com", attrs["domain"].AsString())
+	assert.Equal(t, int64(2), attrs["attempt"].AsInt64())
+	assert.Equal(t, "1s", attrs["elapsed"].AsString())
+
+	assert.Nil(t, errotel.Attributes(nil))
+
+	attrs = map[attribute.Key]attribute.Value{}
+	for _, kv := range errotel.Attributes(io.EOF) {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.NotContains(t, attrs, semconv.ExceptionStacktraceKey)
+}
+
+func TestExemplar(t *testing.T) {
+	assert.Nil(t, errotel.Exemplar(context.Background()))
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02},
+		SpanID:     trace.SpanID{0x03},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	assert.Equal(t, map[string]string{
+		errotel.ExemplarTraceID: sc.TraceID().String(),
+		errotel.ExemplarSpanID:  sc.SpanID().String(),
+	}, errotel.Exemplar(ctx))
+
+	// Unsampled traces are not exported, so cannot be linked
+	unsampled := trace.ContextWithSpanContext(context.Background(), sc.WithTraceFlags(0))
+	assert.Nil(t, errotel.Exemplar(unsampled))
+}
+
+func TestExporterTimestamp(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	errors.NowFunc = func() time.Time { return now }
+	defer func() { errors.NowFunc = time.Now }()
+
+	rec := logtest.NewRecorder()
+	exporter := errotel.NewExporter(rec)
+	exporter.Emit(context.Background(), io.EOF)
+
+	result := rec.Result()
+	require.Len(t, result, 1)
+	require.Len(t, result[0].Records, 1)
+	assert.Equal(t, now, result[0].Records[0].Timestamp())
+	assert.Equal(t, now, result[0].Records[0].ObservedTimestamp())
+}
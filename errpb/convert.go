@@ -0,0 +1,83 @@
+// Package errpb provides a protobuf representation of errors, such that errors
+// can be carried inside existing protobuf payloads and gRPC status details.
+package errpb
+
+import (
+	"fmt"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ToProto returns the protobuf representation of err, with only the fields permitted
+// by errors.SetExportAllowlist().
+// If err is nil, ToProto returns nil.
+func ToProto(err error) *Error {
+	return FromEnvelope(errors.ToEnvelope(err).Exportable())
+}
+
+// FromProto reconstructs an error from its protobuf representation (see errors.Envelope.ToError()).
+// If pb is nil, FromProto returns nil.
+func FromProto(pb *Error) error {
+	if pb == nil {
+		return nil
+	}
+	return pb.ToEnvelope().ToError()
+}
+
+// FromEnvelope returns the protobuf representation of the envelope. Field values
+// which cannot be represented by structpb.Value are converted using fmt.Sprint().
+func FromEnvelope(env *errors.Envelope) *Error {
+	if env == nil {
+		return nil
+	}
+	pb := Error{
+		Version:  int32(env.Version),
+		Message:  env.Message,
+		Type:     env.Type,
+		Messages: env.Messages,
+		Code:     env.Code,
+		Kind:     string(env.Kind),
+		Id:       env.ID,
+		Status:   int32(env.Status),
+	}
+	if len(env.Fields) != 0 {
+		pb.Fields = make(map[string]*structpb.Value, len(env.Fields))
+		for key, value := range env.Fields {
+			v, err := structpb.NewValue(value)
+			if err != nil {
+				v = structpb.NewStringValue(fmt.Sprint(value))
+			}
+			pb.Fields[key] = v
+		}
+	}
+	for _, f := range env.Frames {
+		pb.Frames = append(pb.Frames, &Frame{Func: f.Func, File: f.File, Line: int32(f.Line)})
+	}
+	return &pb
+}
+
+// ToEnvelope returns the envelope represented by the protobuf message
+func (x *Error) ToEnvelope() *errors.Envelope {
+	env := errors.Envelope{
+		Version:  int(x.GetVersion()),
+		Message:  x.GetMessage(),
+		Type:     x.GetType(),
+		Messages: x.GetMessages(),
+		Code:     x.GetCode(),
+		Kind:     errors.Kind(x.GetKind()),
+		ID:       x.GetId(),
+		Status:   int(x.GetStatus()),
+	}
+	if len(x.GetFields()) != 0 {
+		env.Fields = make(map[string]any, len(x.GetFields()))
+		for key, value := range x.GetFields() {
+			env.Fields[key] = value.AsInterface()
+		}
+	}
+	for _, f := range x.GetFrames() {
+		env.Frames = append(env.Frames, callstack.FrameRecord{Func: f.GetFunc(), File: f.GetFile(), Line: int(f.GetLine())})
+	}
+	return &env
+}
@@ -0,0 +1,50 @@
+package errpb_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/errpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProto(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while fetching",
+		errors.WithCode("domain.not_found"),
+		errors.WithKind("not_found"),
+		errors.WithID("abc123"),
+		errors.WithStatus(http.StatusNotFound),
+		errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com", "attempt": 2, "elapsed": time.Second}),
+	)
+
+	b, merr := proto.Marshal(errpb.ToProto(err))
+	require.NoError(t, merr)
+
+	var pb errpb.Error
+	require.NoError(t, proto.Unmarshal(b, &pb))
+	assert.Equal(t, int32(errors.EnvelopeVersion), pb.GetVersion())
+	assert.Equal(t, "while fetching: EOF", pb.GetMessage())
+	assert.Equal(t, "domain.not_found", pb.GetCode())
+	assert.Equal(t, "not_found", pb.GetKind())
+	assert.Equal(t, "abc123", pb.GetId())
+	assert.Equal(t, int32(http.StatusNotFound), pb.GetStatus())
+	assert.Equal(t, "example.com", pb.GetFields()["domain.id"].GetStringValue())
+	assert.Equal(t, float64(2), pb.GetFields()["attempt"].GetNumberValue())
+	assert.Equal(t, "1s", pb.GetFields()["elapsed"].GetStringValue())
+	require.NotEmpty(t, pb.GetFrames())
+	assert.Equal(t, "errpb_test.TestProto", pb.GetFrames()[0].GetFunc())
+
+	decoded := errpb.FromProto(&pb)
+	assert.Equal(t, "while fetching: EOF", decoded.Error())
+	assert.Equal(t, "domain.not_found", errors.CodeOf(decoded))
+	assert.Equal(t, "example.com", errors.ToMap(decoded)["domain.id"])
+
+	assert.Equal(t, errors.ToEnvelope(err).Frames, pb.ToEnvelope().Frames)
+	assert.Nil(t, errpb.ToProto(nil))
+	assert.Nil(t, errpb.FromProto(nil))
+}
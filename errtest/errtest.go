@@ -0,0 +1,82 @@
+// Package errtest provides test helpers which verify the production configuration
+// of the errors package is in effect, for teams which must demonstrate in tests
+// that stack traces and PII do not leave the service.
+package errtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mailgun/errors"
+)
+
+// TestingT is the subset of testing.TB used by the assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertNoStack asserts that no error in err's chain carries a stack trace, as is the
+// case when stack capture is disabled using MG_ERRORS_STACKS=off, the errors_nostack
+// build tag, or callstack.CaptureStacks. It returns true if the assertion passed.
+//
+//	errtest.AssertNoStack(t, handler.Do(req))
+func AssertNoStack(t TestingT, err error) bool {
+	t.Helper()
+	if trace, ok := errors.StackOf(err); ok && len(trace) != 0 {
+		t.Errorf("expected error to have no stack trace, found %d frames starting at %s",
+			len(trace), errors.ToMap(err)["excFuncName"])
+		return false
+	}
+	return true
+}
+
+// AssertRedacted asserts that the value of each of the field keys attached to err never
+// leaves the service unredacted. The field must be removed, redacted or hashed (see
+// errors.IsRedacted()) by the redactors (see errors.RegisterRedactor()) applied to
+// errors.ToMap(), errors.FieldsOf() and errors.ToEnvelope(), and by errors.Export(),
+// which uses errors.DefaultExportOptions as configured for production, such as by
+// MG_ERRORS_REDACT. It returns true if the assertion passed.
+//
+//	errtest.AssertRedacted(t, err, "email", "password")
+func AssertRedacted(t TestingT, err error, keys ...string) bool {
+	t.Helper()
+	var f errors.HasFields
+	if !errors.As(err, &f) {
+		return true
+	}
+	raw := f.HasFields()
+
+	var envelope map[string]any
+	if env := errors.ToEnvelope(err); env != nil {
+		envelope = env.Fields
+	}
+	exports := []struct {
+		name   string
+		fields map[string]any
+	}{
+		{name: "errors.ToMap()", fields: errors.ToMap(err)},
+		{name: "errors.FieldsOf()", fields: errors.FieldsOf(err)},
+		{name: "errors.ToEnvelope()", fields: envelope},
+		{name: "errors.Export()", fields: errors.ToMap(errors.Export(err))},
+	}
+
+	passed := true
+	for _, key := range keys {
+		rawValue, ok := raw[key]
+		if !ok {
+			continue
+		}
+		for _, export := range exports {
+			value, ok := export.fields[key]
+			if !ok || errors.IsRedacted(value) {
+				continue
+			}
+			if strings.Contains(fmt.Sprint(value), fmt.Sprint(rawValue)) {
+				t.Errorf("expected field '%s' to be redacted by %s, found '%v'", key, export.name, value)
+				passed = false
+			}
+		}
+	}
+	return passed
+}
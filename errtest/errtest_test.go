@@ -0,0 +1,84 @@
+package errtest_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"github.com/mailgun/errors/errtest"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNoStack(t *testing.T) {
+	r := &recorder{}
+	assert.False(t, errtest.AssertNoStack(r, errors.Wrap(io.EOF, "with stack")))
+	assert.Len(t, r.failures, 1)
+	assert.Contains(t, r.failures[0], "errtest_test.TestAssertNoStack")
+
+	callstack.CaptureStacks = false
+	defer func() { callstack.CaptureStacks = true }()
+
+	r = &recorder{}
+	assert.True(t, errtest.AssertNoStack(r, errors.Wrap(io.EOF, "without stack")))
+	assert.True(t, errtest.AssertNoStack(r, io.EOF))
+	assert.Empty(t, r.failures)
+}
+
+func TestAssertRedacted(t *testing.T) {
+	err := errors.Fields{"email": "user@example.com"}.Wrap(io.EOF, "while sending")
+
+	defer func(opts errors.ExportOptions) { errors.DefaultExportOptions = opts }(errors.DefaultExportOptions)
+	errors.DefaultExportOptions = errors.ExportOptions{AllowFields: []string{"email"}}
+
+	r := &recorder{}
+	assert.False(t, errtest.AssertRedacted(r, err, "email"))
+	assert.Equal(t, []string{
+		"expected field 'email' to be redacted by errors.ToMap(), found 'user@example.com'",
+		"expected field 'email' to be redacted by errors.FieldsOf(), found 'user@example.com'",
+		"expected field 'email' to be redacted by errors.ToEnvelope(), found 'user@example.com'",
+		"expected field 'email' to be redacted by errors.Export(), found 'user@example.com'",
+	}, r.failures)
+
+	// Redacting only the exported copy still leaks the field through ToMap() and friends
+	errors.DefaultExportOptions.RedactFields = []string{"email"}
+	r = &recorder{}
+	assert.False(t, errtest.AssertRedacted(r, err, "email"))
+	assert.Len(t, r.failures, 3)
+
+	remove := errors.RegisterRedactor(errors.HashRedactor([]byte("salt"), "email"))
+	defer remove()
+	r = &recorder{}
+	assert.True(t, errtest.AssertRedacted(r, err, "email"))
+	assert.Empty(t, r.failures)
+
+	// Fields which are not exported by Export() must still be redacted everywhere else
+	errors.DefaultExportOptions = errors.ExportOptions{}
+	assert.True(t, errtest.AssertRedacted(r, err, "email"))
+	assert.Empty(t, r.failures)
+
+	remove()
+	r = &recorder{}
+	assert.False(t, errtest.AssertRedacted(r, err, "email"))
+	assert.Len(t, r.failures, 3)
+
+	// A value which is redacted in part no longer contains the raw value
+	remove = errors.RegisterRedactor(func(key string, value any) (any, bool) {
+		return "[REDACTED]@example.com", key == "email"
+	})
+	defer remove()
+	r = &recorder{}
+	assert.True(t, errtest.AssertRedacted(r, err, "email"))
+	assert.Empty(t, r.failures)
+}
@@ -0,0 +1,40 @@
+package errors
+
+// subscribers are the functions registered using Subscribe()
+var subscribers hookList
+
+// Subscribe registers fn to receive every error passed to Publish(), such that metrics,
+// sampling and external reporters can be attached in one place without coupling the
+// code which publishes errors to any of them. Subscribers are called synchronously in
+// the order they subscribed, and must not block. Subscribe returns a function which
+// removes the subscriber.
+//
+//	r := errors.NewReporter(errors.LogSink(slog.Default()), errors.ReporterConfig{Limit: 10})
+//	unsubscribe := errors.Subscribe(r.Report)
+//	defer unsubscribe()
+//
+// To also publish every failure when it is first wrapped, register Publish as a hook.
+//
+//	errors.OnWrap(errors.Publish)
+func Subscribe(fn func(err error)) (unsubscribe func()) {
+	return subscribers.add(fn)
+}
+
+// Publish passes err to every subscriber registered using Subscribe().
+// If err is nil, Publish does nothing.
+//
+//	if err := worker.Run(ctx); err != nil {
+//		errors.Publish(err)
+//	}
+func Publish(err error) {
+	if err == nil {
+		return
+	}
+	hooks := subscribers.load()
+	if hooks == nil {
+		return
+	}
+	for _, h := range *hooks {
+		h.fn(err)
+	}
+}
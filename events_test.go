@@ -0,0 +1,36 @@
+package errors_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	var first, second []error
+	unsubscribeFirst := errors.Subscribe(func(err error) { first = append(first, err) })
+	unsubscribeSecond := errors.Subscribe(func(err error) { second = append(second, err) })
+	defer unsubscribeSecond()
+
+	errors.Publish(io.EOF)
+	errors.Publish(nil)
+	assert.Equal(t, []error{io.EOF}, first)
+	assert.Equal(t, []error{io.EOF}, second)
+
+	unsubscribeFirst()
+	errors.Publish(io.ErrUnexpectedEOF)
+	assert.Equal(t, []error{io.EOF}, first)
+	assert.Equal(t, []error{io.EOF, io.ErrUnexpectedEOF}, second)
+}
+
+func TestSubscribeOnWrap(t *testing.T) {
+	var published []error
+	defer errors.Subscribe(func(err error) { published = append(published, err) })()
+	defer errors.OnWrap(errors.Publish)()
+
+	err := errors.Wrap(io.EOF, "while reading")
+	_ = errors.Wrap(err, "while loading")
+	assert.Equal(t, []error{err}, published)
+}
@@ -0,0 +1,71 @@
+package errors
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	exitCodesMu   sync.RWMutex
+	codeExitCodes = map[string]int{}
+	kindExitCodes = map[Kind]int{}
+)
+
+// RegisterCodeExitCode maps an error code (see WithCode()) to a process exit code.
+// It returns a function which restores the previous mapping.
+func RegisterCodeExitCode(code string, exitCode int) (remove func()) {
+	exitCodesMu.Lock()
+	defer exitCodesMu.Unlock()
+	return put(&exitCodesMu, codeExitCodes, code, exitCode)
+}
+
+// RegisterKindExitCode maps a Kind (see WithKind()) to a process exit code.
+// It returns a function which restores the previous mapping.
+func RegisterKindExitCode(kind Kind, exitCode int) (remove func()) {
+	exitCodesMu.Lock()
+	defer exitCodesMu.Unlock()
+	return put(&exitCodesMu, kindExitCodes, kind, exitCode)
+}
+
+// ExitCode returns the process exit code for err. If err is nil it returns 0.
+// Otherwise the exit code is chosen from the first of
+//
+//   - an error in the chain with an `ExitCode() int` method, such as *exec.ExitError
+//   - the exit code registered for the error code of err
+//   - the exit code registered for the Kind of err
+//
+// If none are found, it returns 1.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var e interface{ ExitCode() int }
+	if As(err, &e) {
+		return e.ExitCode()
+	}
+
+	exitCodesMu.RLock()
+	defer exitCodesMu.RUnlock()
+	if exitCode, ok := codeExitCodes[CodeOf(err)]; ok {
+		return exitCode
+	}
+	if exitCode, ok := kindExitCodes[KindOf(err)]; ok {
+		return exitCode
+	}
+	return 1
+}
+
+// FatalIf prints the error to stderr and exits the process with the exit code returned
+// by ExitCode(), if err is non-nil. It is intended for use in command line tools.
+//
+//	func main() {
+//		errors.FatalIf(run(os.Args))
+//	}
+func FatalIf(err error) {
+	if err == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	os.Exit(ExitCode(err))
+}
@@ -0,0 +1,54 @@
+package errors_test
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	removeCode := errors.RegisterCodeExitCode("config.invalid", 78)
+	defer removeCode()
+	removeKind := errors.RegisterKindExitCode("unavailable", 69)
+
+	assert.Equal(t, 0, errors.ExitCode(nil))
+	assert.Equal(t, 1, errors.ExitCode(io.EOF))
+	assert.Equal(t, 78, errors.ExitCode(errors.Wrap(
+		errors.WrapOpts(io.EOF, "config", errors.WithCode("config.invalid"), errors.WithKind("unavailable")), "top")))
+	assert.Equal(t, 69, errors.ExitCode(errors.WrapOpts(io.EOF, "upstream", errors.WithKind("unavailable"))))
+	assert.Equal(t, 1, errors.ExitCode(errors.WrapOpts(io.EOF, "unknown", errors.WithKind("unknown"))))
+
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	assert.Equal(t, 3, errors.ExitCode(errors.Wrap(err, "while running command")))
+
+	// Removing a registration restores the previous mapping
+	restore := errors.RegisterKindExitCode("unavailable", 75)
+	assert.Equal(t, 75, errors.ExitCode(errors.WrapOpts(io.EOF, "upstream", errors.WithKind("unavailable"))))
+	restore()
+	assert.Equal(t, 69, errors.ExitCode(errors.WrapOpts(io.EOF, "upstream", errors.WithKind("unavailable"))))
+	removeKind()
+	assert.Equal(t, 1, errors.ExitCode(errors.WrapOpts(io.EOF, "upstream", errors.WithKind("unavailable"))))
+}
+
+func TestFatalIf(t *testing.T) {
+	if os.Getenv("TEST_FATAL_IF") == "1" {
+		errors.FatalIf(nil)
+		errors.RegisterKindExitCode("unavailable", 69)
+		errors.FatalIf(errors.WrapOpts(io.EOF, "upstream", errors.WithKind("unavailable")))
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalIf$")
+	cmd.Env = append(os.Environ(), "TEST_FATAL_IF=1")
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 69, exitErr.ExitCode())
+	assert.Contains(t, string(out), "error: upstream: EOF")
+}
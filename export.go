@@ -0,0 +1,191 @@
+package errors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the value of fields listed in ExportOptions.RedactFields
+const Redacted = "[REDACTED]"
+
+// HashPrefix is the prefix of values returned by HashValue()
+const HashPrefix = "sha256:"
+
+// HashValue returns a salted hash of the value, for use in place of Redacted when the
+// value should remain correlatable across errors ("the same token in 40 errors")
+// without being exposed. The salt should be a secret which is the same for all the
+// instances of a service, otherwise low entropy values can be recovered by brute force.
+func HashValue(salt []byte, value any) string {
+	h := hmac.New(sha256.New, salt)
+	_, _ = fmt.Fprint(h, value)
+	return HashPrefix + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// IsRedacted returns true if value was replaced by Redacted or HashValue()
+func IsRedacted(value any) bool {
+	s, ok := value.(string)
+	return ok && (s == Redacted || strings.HasPrefix(s, HashPrefix))
+}
+
+// HashRedactor returns a Redactor for RegisterRedactor() which replaces the values of
+// the fields with the keys provided with HashValue().
+//
+//	errors.RegisterRedactor(errors.HashRedactor(salt, "token", "email"))
+func HashRedactor(salt []byte, keys ...string) Redactor {
+	set := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		set[key] = struct{}{}
+	}
+	return func(key string, value any) (any, bool) {
+		if _, ok := set[key]; !ok {
+			return nil, false
+		}
+		return HashValue(salt, value), true
+	}
+}
+
+var (
+	allowlistMu sync.RWMutex
+	allowlist   map[string]struct{}
+)
+
+// SetExportAllowlist restricts the fields which ever leave the service to the keys
+// provided, regardless of the fields requested by ExportOptions.AllowFields or the
+// safe fields passed to errhttp.SetHeaders() and the errgrpc interceptors. It also
+// applies to the envelopes encoded by ToJSON(), errmsgpack.ToMsgpack(), errpb.ToProto()
+// and encoding/gob, but not to ToEnvelope() or ToMap() which are used for logging.
+// This guarantees that context attached for internal use, such as credentials, is
+// never exposed by a handler which allows too much. Calling it again replaces the
+// allowlist, calling it without keys removes the restriction. It should be called
+// once at startup.
+//
+//	errors.SetExportAllowlist("user_id", "domain", "limit")
+func SetExportAllowlist(keys ...string) {
+	allowlistMu.Lock()
+	defer allowlistMu.Unlock()
+	if len(keys) == 0 {
+		allowlist = nil
+		return
+	}
+	allowlist = make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		allowlist[key] = struct{}{}
+	}
+}
+
+// ExportAllowed returns true if the field key is permitted by SetExportAllowlist(),
+// for use by code which exports fields across a trust boundary.
+func ExportAllowed(key string) bool {
+	allowlistMu.RLock()
+	defer allowlistMu.RUnlock()
+	if allowlist == nil {
+		return true
+	}
+	_, ok := allowlist[key]
+	return ok
+}
+
+// Exportable returns a copy of the envelope with only the fields permitted by
+// SetExportAllowlist(), for encodings which send the envelope to another service.
+// If e is nil, Exportable returns nil.
+func (e *Envelope) Exportable() *Envelope {
+	if e == nil || len(e.Fields) == 0 {
+		return e
+	}
+	allowlistMu.RLock()
+	defer allowlistMu.RUnlock()
+	if allowlist == nil {
+		return e
+	}
+	c := *e
+	c.Fields = make(map[string]any, len(e.Fields))
+	for key, value := range e.Fields {
+		if _, ok := allowlist[key]; ok {
+			c.Fields[key] = value
+		}
+	}
+	return &c
+}
+
+// ExportOptions controls which details of an error are retained by ExportOpts()
+type ExportOptions struct {
+	// AllowFields lists the field keys which are retained, all other fields are dropped.
+	// Keys which are not permitted by SetExportAllowlist() are dropped as well.
+	AllowFields []string
+	// RedactFields lists the field keys whose values are replaced with Redacted,
+	// even if they are listed in AllowFields
+	RedactFields []string
+	// HashSalt if non empty replaces the values of RedactFields with HashValue()
+	// instead of Redacted, such that they remain correlatable across errors
+	HashSalt []byte
+	// Redact if non nil is applied to the error message, for instance to remove
+	// file paths or hostnames included by the wrapped errors
+	Redact func(msg string) string
+}
+
+// DefaultExportOptions are the options used by Export()
+var DefaultExportOptions = ExportOptions{}
+
+// Export returns a sanitized copy of err using DefaultExportOptions, see ExportOpts()
+func Export(err error) error {
+	return ExportOpts(err, DefaultExportOptions)
+}
+
+// ExportOpts returns a sanitized copy of err which is safe to serialize into API
+// responses. The copy has no stack trace and does not wrap the original chain, it
+// retains the message, code, kind, id and status of err along with the allowed fields.
+// The redactors registered using RegisterRedactor() are applied to the allowed fields,
+// such that the copy never holds the raw values.
+//
+//	resp := errors.ExportOpts(err, errors.ExportOptions{
+//		AllowFields:  []string{"domain", "limit"},
+//		RedactFields: []string{"token"},
+//	})
+//
+// If err is nil, ExportOpts returns nil.
+func ExportOpts(err error, opts ExportOptions) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if opts.Redact != nil {
+		msg = opts.Redact(msg)
+	}
+	msg = limitMsg(msg)
+
+	var exported Fields
+	var f HasFields
+	if As(err, &f) {
+		all := f.HasFields()
+		for _, key := range opts.AllowFields {
+			if value, ok := all[key]; ok && ExportAllowed(key) {
+				exported = exported.set(key, value)
+			}
+		}
+		for _, key := range opts.RedactFields {
+			value, ok := exported[key]
+			if !ok {
+				continue
+			}
+			if len(opts.HashSalt) != 0 {
+				exported[key] = HashValue(opts.HashSalt, value)
+				continue
+			}
+			exported[key] = Redacted
+		}
+		exported = redactFields(exported)
+	}
+
+	return WrapOpts(New(msg), NoMsg,
+		WithCode(CodeOf(err)),
+		WithKind(KindOf(err)),
+		WithStatus(StatusOf(err)),
+		WithID(IDOf(err)),
+		WithFieldsOpt(exported),
+		NoStack(),
+	)
+}
@@ -0,0 +1,126 @@
+package errors_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportOpts(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while reading /var/lib/app/db",
+		errors.WithCode("storage.read"),
+		errors.WithStatus(503),
+		errors.WithFieldsOpt(errors.Fields{
+			"domain": "example.com",
+			"token":  "secret",
+			"query":  "SELECT * FROM users",
+		}),
+	)
+
+	exported := errors.ExportOpts(err, errors.ExportOptions{
+		AllowFields:  []string{"domain", "token"},
+		RedactFields: []string{"token"},
+		Redact: func(msg string) string {
+			return strings.ReplaceAll(msg, "/var/lib/app/db", "<path>")
+		},
+	})
+	require.Error(t, exported)
+	assert.Equal(t, "while reading <path>: EOF", exported.Error())
+	assert.False(t, errors.Is(exported, io.EOF))
+
+	m := errors.ToMap(exported)
+	assert.Equal(t, "example.com", m["domain"])
+	assert.Equal(t, errors.Redacted, m["token"])
+	assert.NotContains(t, m, "query")
+	assert.NotContains(t, m, "excFuncName")
+
+	env := errors.ToEnvelope(exported)
+	assert.Equal(t, "storage.read", env.Code)
+	assert.Equal(t, 503, env.Status)
+	assert.Empty(t, env.Frames)
+}
+
+func TestExport(t *testing.T) {
+	exported := errors.Export(errors.Fields{"token": "secret"}.Wrap(io.EOF, "while reading"))
+	assert.Equal(t, "while reading: EOF", exported.Error())
+	assert.NotContains(t, errors.ToMap(exported), "token")
+	assert.NoError(t, errors.Export(nil))
+}
+
+func TestExportHashSalt(t *testing.T) {
+	salt := []byte("salt")
+	first := errors.Fields{"token": "secret"}.Wrap(io.EOF, "first")
+	second := errors.Fields{"token": "secret"}.Wrap(io.EOF, "second")
+	other := errors.Fields{"token": "other"}.Wrap(io.EOF, "other")
+
+	opts := errors.ExportOptions{AllowFields: []string{"token"}, RedactFields: []string{"token"}, HashSalt: salt}
+	hashed := errors.ToMap(errors.ExportOpts(first, opts))["token"]
+
+	assert.Regexp(t, `^sha256:[0-9a-f]{16}$`, hashed)
+	assert.True(t, errors.IsRedacted(hashed))
+	assert.Equal(t, hashed, errors.ToMap(errors.ExportOpts(second, opts))["token"])
+	assert.NotEqual(t, hashed, errors.ToMap(errors.ExportOpts(other, opts))["token"])
+	assert.NotEqual(t, hashed, errors.HashValue([]byte("pepper"), "secret"))
+	assert.Equal(t, hashed, errors.HashValue(salt, "secret"))
+}
+
+func TestHashRedactor(t *testing.T) {
+	r := errors.HashRedactor([]byte("salt"), "token")
+
+	value, ok := r("token", "secret")
+	assert.True(t, ok)
+	assert.Equal(t, errors.HashValue([]byte("salt"), "secret"), value)
+
+	_, ok = r("domain", "example.com")
+	assert.False(t, ok)
+
+	assert.True(t, errors.IsRedacted(errors.Redacted))
+	assert.False(t, errors.IsRedacted("secret"))
+	assert.False(t, errors.IsRedacted(42))
+}
+
+func TestSetExportAllowlist(t *testing.T) {
+	errors.SetExportAllowlist("domain", "limit")
+	defer errors.SetExportAllowlist()
+
+	assert.True(t, errors.ExportAllowed("domain"))
+	assert.False(t, errors.ExportAllowed("token"))
+
+	err := errors.Fields{"domain": "example.com", "token": "secret"}.Wrap(io.EOF, "while reading")
+	exported := errors.ExportOpts(err, errors.ExportOptions{AllowFields: []string{"domain", "token"}})
+	m := errors.ToMap(exported)
+	assert.Equal(t, "example.com", m["domain"])
+	assert.NotContains(t, m, "token")
+
+	// Encodings which leave the service are restricted, the envelope used for logging is not
+	b, jsonErr := errors.ToJSON(err)
+	require.NoError(t, jsonErr)
+	env, jsonErr := errors.ParseJSON(b)
+	require.NoError(t, jsonErr)
+	assert.Equal(t, map[string]any{"domain": "example.com"}, env.Fields)
+	assert.Equal(t, "secret", errors.ToEnvelope(err).Fields["token"])
+	assert.Nil(t, (*errors.Envelope)(nil).Exportable())
+
+	errors.SetExportAllowlist()
+	assert.True(t, errors.ExportAllowed("token"))
+	assert.Equal(t, "secret", errors.ToMap(errors.ExportOpts(err, errors.ExportOptions{AllowFields: []string{"token"}}))["token"])
+}
+
+func TestExportOptsRedactors(t *testing.T) {
+	remove := errors.RegisterRedactor(errors.HashRedactor([]byte("salt"), "email"))
+	defer remove()
+
+	err := errors.Fields{"email": "bob@x.com", "domain": "example.com"}.Wrap(io.EOF, "while sending")
+	exported := errors.ExportOpts(err, errors.ExportOptions{AllowFields: []string{"email", "domain"}})
+
+	var f errors.HasFields
+	require.True(t, errors.As(exported, &f))
+	hashed := errors.HashValue([]byte("salt"), "bob@x.com")
+	assert.Equal(t, map[string]any{"email": hashed, "domain": "example.com"}, f.HasFields())
+	assert.Equal(t, hashed, errors.FieldsOf(exported)["email"])
+	assert.Equal(t, hashed, errors.ToMap(exported)["email"])
+}
@@ -5,8 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/mailgun/errors/callstack"
+	"github.com/sirupsen/logrus"
 )
 
 // HasFields Implement this interface to pass along unstructured context to the logger.
@@ -24,6 +33,184 @@ type HasFormat interface {
 // Fields Creates errors that conform to the `HasFields` interface
 type Fields map[string]any
 
+// FieldsBuilder adds typed fields in place, without allocating a map for small sets
+// of fields. It is created by F() and ends with Fields() or one of its wrapping
+// methods, after which it must no longer be used as the error shares its storage.
+type FieldsBuilder struct {
+	set fieldSet
+}
+
+// F begins a chain of typed field setters which ends with one of the wrapping methods
+//
+//	return errors.F().Str("user", id).Int("attempt", n).Dur("elapsed", d).Wrap(err, "while fetching user")
+func F() *FieldsBuilder {
+	return &FieldsBuilder{set: fieldSet{small: make([]field, 0, 4)}}
+}
+
+// Str adds a string field
+func (b *FieldsBuilder) Str(key, value string) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Int adds an int field
+func (b *FieldsBuilder) Int(key string, value int) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Int64 adds an int64 field
+func (b *FieldsBuilder) Int64(key string, value int64) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Float64 adds a float64 field
+func (b *FieldsBuilder) Float64(key string, value float64) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Bool adds a bool field
+func (b *FieldsBuilder) Bool(key string, value bool) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Dur adds a time.Duration field
+func (b *FieldsBuilder) Dur(key string, value time.Duration) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Time adds a time.Time field
+func (b *FieldsBuilder) Time(key string, value time.Time) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// Any adds a field of any type
+func (b *FieldsBuilder) Any(key string, value any) *FieldsBuilder {
+	return b.add(key, value)
+}
+
+// add sets the key to value, moving the fields to a map once there are too many
+// to search the slice.
+func (b *FieldsBuilder) add(key string, value any) *FieldsBuilder {
+	if b.set.large == nil && len(b.set.small) == maxSmallFields {
+		b.set = setOf(b.set.toMap())
+	}
+	if b.set.large != nil {
+		b.set.large[key] = value
+		return b
+	}
+	b.set.add(key, value)
+	return b
+}
+
+// Fields returns the fields added to the builder
+func (b *FieldsBuilder) Fields() Fields {
+	return b.set.toMap()
+}
+
+// Wrap returns an error annotating err with a stack trace
+// at the point Wrap is called, the fields and the supplied message.
+// If err is nil, Wrap returns nil.
+func (b *FieldsBuilder) Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  b.set,
+		wrapped: err,
+		msg:     msg,
+	})
+}
+
+// Wrapf is identical to Wrap but formats the message using the format specifier.
+// If err is nil, Wrapf returns nil.
+func (b *FieldsBuilder) Wrapf(err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  b.set,
+		wrapped: err,
+		msg:     fmt.Sprintf(format, args...),
+	})
+}
+
+// Stack returns an error annotating err with a stack trace at the point Stack
+// is called and the fields. If err is nil, Stack returns nil.
+func (b *FieldsBuilder) Stack(err error) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  b.set,
+		wrapped: err,
+	})
+}
+
+// Error returns a new error with the message and the fields
+func (b *FieldsBuilder) Error(msg string) error {
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  b.set,
+		wrapped: errors.New(msg),
+	})
+}
+
+// Errorf returns a new error with the formatted message and the fields
+func (b *FieldsBuilder) Errorf(format string, args ...any) error {
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  b.set,
+		wrapped: fmt.Errorf(format, args...),
+	})
+}
+
+// Merge returns a new Fields containing the fields of f and all the fields in other.
+// When a key exists in more than one Fields, the last one provided wins, such that
+// fields in other have precedence over f. Neither f nor other is modified.
+func (f Fields) Merge(other ...Fields) Fields {
+	maps := make([]map[string]any, 0, len(other)+1)
+	maps = append(maps, f)
+	for _, o := range other {
+		maps = append(maps, o)
+	}
+	return MergeFields(maps...)
+}
+
+// MergeFields returns a new Fields containing all the fields in the provided maps.
+// When a key exists in more than one map, the last map provided wins.
+//
+//	// Fields from the request take precedence over the defaults
+//	f := errors.MergeFields(defaults, fromMiddleware, fromRequest)
+func MergeFields(maps ...map[string]any) Fields {
+	var size int
+	for _, m := range maps {
+		size += len(m)
+	}
+	result := make(Fields, size)
+	for _, m := range maps {
+		for key, value := range m {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// with returns a copy of f with the field added
+func (f Fields) set(key string, value any) Fields {
+	if f == nil {
+		f = make(Fields, 4)
+	}
+	f[key] = value
+	return f
+}
+
 // Wrapf returns an error annotating err with a stack trace
 // at the point Wrapf is call, and the format specifier.
 // If err is nil, Wrapf returns nil.
@@ -31,12 +218,13 @@ func (f Fields) Wrapf(err error, format string, args ...any) error {
 	if err == nil {
 		return nil
 	}
-	return &fields{
-		stack:   callstack.New(1),
-		fields:  f,
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  setOf(f),
 		wrapped: err,
 		msg:     fmt.Sprintf(format, args...),
-	}
+	})
 }
 
 // WrapFields returns a new error wrapping the provided error with fields and a message.
@@ -44,12 +232,118 @@ func WrapFields(err error, f Fields, msg string) error {
 	if err == nil {
 		return nil
 	}
-	return &fields{
-		stack:   callstack.New(1),
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		msg:     msg,
+		fields:  setOf(f),
+	})
+}
+
+// WrapElapsed returns a new error wrapping the provided error with a message and
+// the field `elapsed` containing the time.Duration since start.
+//
+//	start := time.Now()
+//	rows, err := db.QueryContext(ctx, query)
+//	if err != nil {
+//		return errors.WrapElapsed(err, start, "while querying users")
+//	}
+func WrapElapsed(err error, start time.Time, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
 		wrapped: err,
 		msg:     msg,
-		fields:  f,
+		fields:  Timer(start).set,
+	})
+}
+
+// Timer returns a FieldsBuilder containing the field `elapsed` with the time.Duration
+// since start, such that further fields and any of the wrapping methods can be used.
+//
+//	return errors.Timer(start).Str("query", name).Wrap(err, "while querying users")
+func Timer(start time.Time) *FieldsBuilder {
+	return F().Dur("elapsed", time.Since(start))
+}
+
+// WrapKV returns a new error wrapping the provided error with a message and fields
+// built from alternating key/value pairs.
+//
+//	return errors.WrapKV(err, "while fetching user", "user_id", id, "region", region)
+//
+// Keys which are not strings are converted using fmt.Sprint(). If the final key has
+// no value, the key is recorded with the value "!MISSING". A slog.Attr may be
+// provided in place of a key/value pair.
+func WrapKV(err error, msg string, kv ...any) error {
+	if err == nil {
+		return nil
 	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		msg:     msg,
+		fields:  kvToSet(kv),
+	})
+}
+
+// kvToFields converts alternating key/value pairs into Fields. Like slog, a
+// slog.Attr in the key position is consumed as a complete field.
+func kvToFields(kv []any) Fields {
+	result := make(Fields, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i++ {
+		if attr, ok := kv[i].(slog.Attr); ok {
+			addAttr(result, "", attr)
+			continue
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		if i+1 == len(kv) {
+			result[key] = "!MISSING"
+			break
+		}
+		i++
+		result[key] = kv[i]
+	}
+	return result
+}
+
+// FieldsFromAttrs converts slog attributes into Fields, such that code which
+// already uses slog attribute helpers can reuse them for error context.
+// Attributes within a group are flattened using the key `<group>.<key>`.
+//
+//	return errors.FieldsFromAttrs(slog.String("user", id), slog.Int("attempt", n)).Wrap(err, "msg")
+func FieldsFromAttrs(attrs ...slog.Attr) Fields {
+	result := make(Fields, len(attrs))
+	for _, attr := range attrs {
+		addAttr(result, "", attr)
+	}
+	return result
+}
+
+func addAttr(f Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if value.Kind() == slog.KindGroup {
+		// Inline groups with empty keys as slog does
+		if attr.Key == "" {
+			key = prefix
+		}
+		for _, a := range value.Group() {
+			addAttr(f, key, a)
+		}
+		return
+	}
+	f[key] = value.Any()
 }
 
 // WrapFieldsf is identical to WrapFields but with optional formatting
@@ -57,12 +351,13 @@ func WrapFieldsf(err error, f Fields, format string, args ...any) error {
 	if err == nil {
 		return nil
 	}
-	return &fields{
+	return observeWrap(&fields{
 		msg:     fmt.Sprintf(format, args...),
-		stack:   callstack.New(1),
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
 		wrapped: err,
-		fields:  f,
-	}
+		fields:  setOf(f),
+	})
 }
 
 // Wrap returns an error annotating err with a stack trace
@@ -72,12 +367,13 @@ func (f Fields) Wrap(err error, msg string) error {
 	if err == nil {
 		return nil
 	}
-	return &fields{
-		stack:   callstack.New(1),
-		fields:  f,
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  setOf(f),
 		wrapped: err,
 		msg:     msg,
-	}
+	})
 }
 
 // Stack returns an error annotating err with a stack trace
@@ -86,45 +382,100 @@ func (f Fields) Stack(err error) error {
 	if err == nil {
 		return nil
 	}
-	return &fields{
-		stack:   callstack.New(1),
-		fields:  f,
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  setOf(f),
 		wrapped: err,
-	}
+	})
 }
 
 func (f Fields) Error(msg string) error {
-	return &fields{
-		stack:   callstack.New(1),
-		fields:  f,
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  setOf(f),
 		wrapped: errors.New(msg),
 		msg:     "",
-	}
+	})
 }
 
 func (f Fields) Errorf(format string, args ...any) error {
-	return &fields{
-		stack:   callstack.New(1),
-		fields:  f,
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  setOf(f),
 		wrapped: fmt.Errorf(format, args...),
 		msg:     "",
+	})
+}
+
+// AddFields returns a new error with the provided fields merged into the fields
+// attached to err, where the provided fields take precedence. Unlike WrapFields()
+// no message segment is added and the stack trace already attached to err is
+// reused. The original error and its fields are never modified.
+//
+// If no error in the chain of err has a stack trace, one is captured at the point
+// AddFields is called. If err is nil, AddFields returns nil.
+func AddFields(err error, f Fields) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *fields:
+		c := *e
+		c.fields = e.fields.merge(f)
+		return &c
 	}
+	var stack callstack.HasStackTrace
+	if As(err, &stack) {
+		return &fields{
+			created: NowFunc(),
+			wrapped: err,
+			msg:     NoMsg,
+			fields:  setOf(f),
+		}
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		fields:  setOf(f),
+	})
 }
 
 type fields struct {
-	fields  Fields
+	fields  fieldSet
 	msg     string
 	wrapped error
-	stack   *callstack.CallStack
+	callstack.Trace
+	created time.Time
 }
 
 func (c *fields) Unwrap() error {
 	return c.wrapped
 }
 
+func (c *fields) ownFields() map[string]any { return c.fields.toMap() }
+
+func (c *fields) message() string { return c.msg }
+
+func (c *fields) clone() error {
+	cp := *c
+	cp.fields = c.fields.clone()
+	cp.wrapped = Clone(c.wrapped)
+	return &cp
+}
+
+func (c *fields) stripStack() error {
+	cp := *c
+	cp.Trace = callstack.Trace{}
+	cp.wrapped = StripStack(c.wrapped)
+	return &cp
+}
+
 func (c *fields) Is(target error) bool {
 	_, ok := target.(*fields)
-	return ok
+	return ok && MatchWrapperType
 }
 
 // Cause returns the wrapped error which was the original
@@ -136,106 +487,481 @@ func (c *fields) Cause() error { return c.wrapped }
 
 func (c *fields) Error() string {
 	if c.msg == NoMsg {
-		return c.wrapped.Error()
+		return limitMsg(c.wrapped.Error())
 	}
-	return c.msg + ": " + c.wrapped.Error()
+	return limitMsg(c.msg + ": " + c.wrapped.Error())
 }
 
 func (c *fields) StackTrace() callstack.StackTrace {
 	if child, ok := c.wrapped.(callstack.HasStackTrace); ok {
 		return child.StackTrace()
 	}
-	return c.stack.StackTrace()
+	return c.Trace.StackTrace()
+}
+
+func (c *fields) CreatedAt() time.Time {
+	return c.created
 }
 
 func (c *fields) HasFields() map[string]any {
-	result := make(map[string]any, len(c.fields))
-	for key, value := range c.fields {
-		result[key] = value
+	return mergeFields(c)
+}
+
+// mergeFields returns the fields attached to err and the errors in its tree. Child fields
+// have precedence as they are closer to the cause, and when more than one joined error
+// has the same key, the first one wins. Each wrapper is merged once even when it appears
+// in the tree more than once, such as when a retry loop joins attempts which wrap the
+// same error, such that the cost is linear in the number of fields in the tree.
+func mergeFields(err error) map[string]any {
+	result := make(map[string]any)
+	mergeFieldsInto(result, err, make(map[error]struct{}))
+	return result
+}
+
+// mergeFieldsInto visits the fields in order of precedence, as such
+// a key is only set if it was not set by an error visited before.
+func mergeFieldsInto(result map[string]any, err error, seen map[error]struct{}) {
+	switch err.(type) {
+	case nil:
+		return
+	case wrapper:
+		if _, ok := seen[err]; ok {
+			return
+		}
+		seen[err] = struct{}{}
+	default:
+		// Errors from other packages already include the fields of their children
+		if f, ok := err.(HasFields); ok {
+			setAbsent(result, f.HasFields())
+			return
+		}
 	}
 
-	// child fields have precedence as they are closer to the cause
-	var f HasFields
-	if errors.As(c.wrapped, &f) {
-		child := f.HasFields()
-		if child == nil {
-			return result
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		mergeFieldsInto(result, e.Unwrap(), seen)
+	case interface{ Unwrap() []error }:
+		for _, child := range e.Unwrap() {
+			mergeFieldsInto(result, child, seen)
 		}
-		for key, value := range child {
+	}
+	if c, ok := err.(*fields); ok {
+		c.fields.each(func(key string, value any) {
+			if _, ok := result[key]; !ok {
+				result[key] = value
+			}
+		})
+		return
+	}
+	setAbsent(result, ownFields(err))
+}
+
+func setAbsent(result map[string]any, f map[string]any) {
+	for key, value := range f {
+		if _, ok := result[key]; !ok {
 			result[key] = value
 		}
 	}
-	return result
+}
+
+func (c *fields) writePlus(w io.Writer) bool {
+	return formatPlus(w, c.msg, c.Unwrap(), " ("+c.FormatFields()+")")
 }
 
 func (c *fields) Format(s fmt.State, verb rune) {
 	switch verb {
 	case 'v':
 		if s.Flag('+') {
-			if c.msg == NoMsg {
-				_, _ = fmt.Fprintf(s, "%+v (%s)", c.Unwrap(), c.FormatFields())
-				return
-			}
-			_, _ = fmt.Fprintf(s, "%s: %+v (%s)", c.msg, c.Unwrap(), c.FormatFields())
+			c.writePlus(s)
 			return
 		}
 		fallthrough
 	case 's', 'q':
-		_, _ = io.WriteString(s, c.Error())
+		_, _ = io.WriteString(s, MessageOf(c))
 		return
 	}
 }
 
+// FormatFields renders the fields as "key1=value1, key2=value2". Values containing
+// spaces, commas, '=', quotes, backslashes or control characters are quoted logfmt
+// style, such that the rendering remains machine parseable.
 func (c *fields) FormatFields() string {
 	var buf bytes.Buffer
 	var count int
 
-	for key, value := range c.fields {
+	c.fields.each(func(key string, value any) {
 		if count > 0 {
 			buf.WriteString(", ")
 		}
-		buf.WriteString(fmt.Sprintf("%+v=%+v", key, value))
+		if SanitizeKeys {
+			key = SanitizeKey(key)
+		}
+		buf.WriteString(fmt.Sprintf("%+v=%s", key, quoteValue(fmt.Sprintf("%+v", value))))
 		count++
-	}
+	})
 	return buf.String()
 }
 
+// EscapeControlChars controls whether newlines and other control characters in field
+// values are escaped when rendered by FormatFields() and `%+v`, such that a multi-line
+// value cannot forge additional log lines. It defaults to true.
+var EscapeControlChars = true
+
+// quoteValue quotes v using strconv.Quote() if it contains characters which would
+// break logfmt parsing. When EscapeControlChars is false, only backslashes and quotes
+// are escaped and control characters are written as is.
+func quoteValue(v string) string {
+	if EscapeControlChars {
+		if strings.ContainsAny(v, ` ,="\`) || strings.IndexFunc(v, unicode.IsControl) != -1 {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	if strings.ContainsAny(v, ` ,="\`) {
+		return `"` + quoteEscaper.Replace(v) + `"`
+	}
+	return v
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// SanitizeKeys controls whether field keys are sanitized using SanitizeKey() when
+// rendered by FormatFields() and ToLogrus(), such that a key like "user name" cannot
+// corrupt logfmt parsing downstream. The raw keys remain available from ToMap().
+// It defaults to false.
+var SanitizeKeys = false
+
+// SanitizeKey returns the key with spaces, '=', quotes and control characters
+// replaced by '_', such that it is safe to use as a logfmt key.
+func SanitizeKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
 // ToMap Returns the fields for the underlying error as map[string]any
 // If no fields are available returns nil
 func ToMap(err error) map[string]any {
+	return ToMapOpts(err, DefaultToMapOptions)
+}
+
+// FieldsOf returns only the fields attached to the errors in the chain, without the
+// `exc` keys added by ToMap(), for merging the error context into a log entry which
+// already has its own message and caller. If no fields are available returns nil.
+//
+//	logger.WithFields(errors.FieldsOf(err)).Warn("retrying request")
+func FieldsOf(err error) map[string]any {
+	var f HasFields
+	if !errors.As(err, &f) {
+		return nil
+	}
+	found := f.HasFields()
+	if len(found) == 0 {
+		return nil
+	}
+	result := make(map[string]any, len(found))
+	for key, value := range found {
+		result[key] = value
+	}
+	return redactFields(result)
+}
+
+// DefaultToMapOptions are the options used by ToMap() and ToLogrus(). It should
+// only be modified once at startup, before any errors are exported.
+//
+//	errors.DefaultToMapOptions.IncludeBuildInfo = true
+var DefaultToMapOptions ToMapOptions
+
+// ToMapOptions tunes the information extracted by ToMapOpts()
+type ToMapOptions struct {
+	// IncludeStack adds the key `excStack` which contains the full call stack
+	// of the last stack trace found in the chain.
+	IncludeStack bool
+	// IncludeFrames adds the key `excFrames` which contains the last stack trace found
+	// in the chain as a []callstack.FrameRecord.
+	IncludeFrames bool
+	// IncludeRawStack adds the key `excRawStack` which contains the last stack trace
+	// found in the chain as an unresolved callstack.RawStack, which is much smaller
+	// than the resolved frames and can be symbolicated later using a callstack.Resolver.
+	IncludeRawStack bool
+	// KeyPrefix is prepended to every key in the result.
+	KeyPrefix string
+	// MaxFields limits the number of fields collected from the chain, not including
+	// the `exc` keys. Fields are chosen in key order. Zero means no limit.
+	MaxFields int
+	// IncludeChain adds the key `excChain` which contains the type of each error
+	// in the chain, starting with err.
+	IncludeChain bool
+	// IncludeRootType adds the key `excRootType` which contains the type of the innermost
+	// error in the chain, as `excType` only reports the type of the error wrapped by err,
+	// which for deep chains is usually another wrapper rather than the cause.
+	IncludeRootType bool
+	// IncludeMessages adds the key `excMessages` which contains the message added by
+	// each error in the chain, starting with err and ending with the root cause.
+	// Errors which add no message, such as Stack(), are omitted.
+	IncludeMessages bool
+	// IncludeBuildInfo adds the keys `excBuildVersion`, `excBuildRevision` and
+	// `excBuildDirty` from the build information embedded in the binary.
+	IncludeBuildInfo bool
+	// IncludeHost adds the keys `excHostname`, `excPID` and when running in a
+	// container `excContainerID`, giving errors consistent origin metadata.
+	IncludeHost bool
+	// Collisions controls how a key attached with different values by more than one
+	// error in the chain is reported, defaults to CollisionOverwrite.
+	Collisions CollisionMode
+}
+
+// CollisionMode controls how ToMapOpts() reports a key attached with different values
+// by more than one error in the chain.
+type CollisionMode int
+
+const (
+	// CollisionOverwrite reports only the value attached closest to the cause
+	CollisionOverwrite CollisionMode = iota
+	// CollisionSuffix reports the value attached closest to the cause under the key,
+	// and each of the other values under the key with the suffix `#2`, `#3` and so on,
+	// in order of distance from the cause.
+	CollisionSuffix
+	// CollisionSlice reports all the values as a []any under the key, in order of
+	// distance from the cause.
+	CollisionSlice
+)
+
+// collectCollisions returns found with the other values of keys which were attached
+// with different values by more than one error in err's tree, reported according to mode.
+// Each value is redacted under its own key before the values are compared, such that
+// the suffixed keys and slices never carry a value the redactors would have replaced.
+func collectCollisions(err error, found map[string]any, mode CollisionMode) map[string]any {
+	var layers []map[string]any
+	WalkTree(err, func(e error, _ []int) bool {
+		if own := ownFields(e); len(own) != 0 {
+			layers = append(layers, own)
+		}
+		return true
+	})
+
+	redactorsMu.RLock()
+	defer redactorsMu.RUnlock()
+	redact := func(key string, value any) any {
+		if redacted, ok := redactValue(key, value); ok {
+			return redacted
+		}
+		return value
+	}
+
+	values := make(map[string][]any, len(found))
+	for key, value := range found {
+		values[key] = []any{redact(key, value)}
+	}
+	// Visit the errors closest to the cause first
+	for i := len(layers) - 1; i >= 0; i-- {
+		for key, value := range layers[i] {
+			value = redact(key, value)
+			if !containsValue(values[key], value) {
+				values[key] = append(values[key], value)
+			}
+		}
+	}
+
+	result := make(map[string]any, len(found))
+	for key, all := range values {
+		if _, ok := found[key]; ok {
+			result[key] = all[0]
+		}
+		if len(all) < 2 {
+			continue
+		}
+		switch mode {
+		case CollisionSuffix:
+			for i, value := range all[1:] {
+				result[fmt.Sprintf("%s#%d", key, i+2)] = value
+			}
+		case CollisionSlice:
+			result[key] = all
+		}
+	}
+	return result
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeNames caches the name of each type reported as excType, as formatting the
+// type using %T is comparatively expensive in the logging hot path.
+var typeNames sync.Map
+
+// typeName returns the same name as fmt.Sprintf("%T", v)
+func typeName(v any) string {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return "<nil>"
+	}
+	if name, ok := typeNames.Load(t); ok {
+		return name.(string)
+	}
+	name := t.String()
+	typeNames.Store(t, name)
+	return name
+}
+
+// ToMapOpts is identical to ToMap but allows the caller to tune what is extracted
+//
+//	m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeStack: true, KeyPrefix: "err_"})
+func ToMapOpts(err error, opts ToMapOptions) map[string]any {
 	if err == nil {
 		return nil
 	}
 
 	result := map[string]any{
-		"excValue": err.Error(),
-		"excType":  fmt.Sprintf("%T", Unwrap(err)),
+		"excValue": MessageOf(err),
+		"excType":  typeName(Unwrap(err)),
 	}
 
 	// Find any errors with StackTrace information if available
 	var stack callstack.HasStackTrace
-	if Last(err, &stack) {
+	if lastInChain(err, &stack) {
 		trace := stack.StackTrace()
 		caller := callstack.GetLastFrame(trace)
 		result["excFuncName"] = caller.Func
 		result["excLineNum"] = caller.LineNo
 		result["excFileName"] = caller.File
+		if e, ok := stack.(callstack.HasElidedFrames); ok && e.ElidedFrames() > 0 {
+			result["excFramesElided"] = e.ElidedFrames()
+		}
+		if opts.IncludeStack {
+			result["excStack"] = caller.CallStack
+		}
+		if opts.IncludeFrames {
+			result["excFrames"] = trace.Records()
+		}
+		if opts.IncludeRawStack {
+			result["excRawStack"] = trace.Raw()
+		}
+	}
+
+	if opts.IncludeChain {
+		var chain []string
+		for e := err; e != nil; e = Unwrap(e) {
+			chain = append(chain, typeName(e))
+		}
+		result["excChain"] = chain
+	}
+
+	if opts.IncludeRootType {
+		result["excRootType"] = typeName(Cause(err))
+	}
+
+	if opts.IncludeBuildInfo {
+		for key, value := range buildInfo() {
+			result[key] = value
+		}
+	}
+
+	if opts.IncludeHost {
+		for key, value := range hostInfo() {
+			result[key] = value
+		}
+	}
+
+	if opts.IncludeMessages {
+		result["excMessages"] = chainMessages(err)
 	}
 
 	// Search the error chain for fields
 	var f HasFields
 	if errors.As(err, &f) {
-		for key, value := range f.HasFields() {
-			result[key] = value
+		found := f.HasFields()
+		if opts.Collisions != CollisionOverwrite {
+			// The collisions are redacted as they are collected
+			found = collectCollisions(err, found, opts.Collisions)
+		} else {
+			found = redactFields(found)
+		}
+		keys := make([]string, 0, len(found))
+		for key := range found {
+			keys = append(keys, key)
+		}
+		if opts.MaxFields > 0 && len(keys) > opts.MaxFields {
+			sort.Strings(keys)
+			keys = keys[:opts.MaxFields]
+		}
+		for _, key := range keys {
+			result[key] = found[key]
 		}
 	}
+
+	if opts.KeyPrefix != "" {
+		prefixed := make(map[string]any, len(result))
+		for key, value := range result {
+			prefixed[opts.KeyPrefix+key] = value
+		}
+		return prefixed
+	}
 	return result
 }
 
+// chainMessages returns the message segment contributed by each error in the chain.
+// The wrappers of this package know their own message, for other errors the message
+// of the error they wrap is trimmed from their own when it is the suffix.
+func chainMessages(err error) []string {
+	var messages []string
+	for err != nil {
+		next := Unwrap(err)
+		var msg string
+		if w, ok := err.(wrapper); ok {
+			msg = w.message()
+		} else {
+			msg = err.Error()
+			if next != nil {
+				msg = strings.TrimSuffix(msg, next.Error())
+				msg = strings.TrimSuffix(msg, ": ")
+			}
+		}
+		if msg != "" {
+			messages = append(messages, limitMsg(msg))
+		}
+		err = next
+	}
+	return messages
+}
+
 // ToLogrus Returns the context and stacktrace information for the underlying error
 // that could be used as logrus.Fields
 //
 //	logrus.Fields(errors.ToLogrus(err)).WithField("tid", 1).Error(err)
 func ToLogrus(err error) map[string]any {
-	return ToMap(err)
+	m := ToMap(err)
+	if !SanitizeKeys || m == nil {
+		return m
+	}
+	result := make(map[string]any, len(m))
+	for key, value := range m {
+		result[SanitizeKey(key)] = value
+	}
+	return result
+}
+
+// LogrusEntry returns a logrus entry with the fields and stack trace information
+// from the error, the provided extra fields and the error itself under
+// logrus.ErrorKey. Extra fields have precedence over fields from the error.
+//
+//	errors.LogrusEntry(logrus.StandardLogger(), err, errors.Fields{"tid": 1}).Error("while handling request")
+func LogrusEntry(logger logrus.FieldLogger, err error, extra Fields) *logrus.Entry {
+	f := logrus.Fields(MergeFields(ToLogrus(err), extra))
+	if err != nil {
+		f[logrus.ErrorKey] = err
+	}
+	return logger.WithFields(f)
 }
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,7 +30,7 @@ func TestToMapToLogrusFindsLastStackTrace(t *testing.T) {
 	t.Run("ToMap() finds the last stack in the chain", func(t *testing.T) {
 		m := errors.ToMap(err)
 		assert.NotNil(t, m)
-		assert.Equal(t, 21, m["excLineNum"])
+		assert.Equal(t, 24, m["excLineNum"])
 	})
 
 	t.Run("ToLogrus() finds the last stack in the chain", func(t *testing.T) {
@@ -38,7 +41,7 @@ func TestToMapToLogrusFindsLastStackTrace(t *testing.T) {
 		logrus.WithFields(f).Info("test logrus fields")
 		logrus.SetOutput(os.Stdout)
 		fmt.Printf("%s\n", b.String())
-		assert.Contains(t, b.String(), "excLineNum=21")
+		assert.Contains(t, b.String(), "excLineNum=24")
 	})
 }
 
@@ -254,3 +257,534 @@ func TestFieldsCause(t *testing.T) {
 	err := errors.Fields{"key1": "value1"}.Wrap(io.EOF, "message")
 	assert.Equal(t, io.EOF, pkgErrorCause(err))
 }
+
+func TestAddFields(t *testing.T) {
+	t.Run("merges into existing fields", func(t *testing.T) {
+		err := errors.Fields{"key1": "value1", "key2": "value2"}.Wrap(io.EOF, "message")
+		added := errors.AddFields(err, errors.Fields{"key2": "override", "key3": "value3"})
+
+		assert.Equal(t, "message: EOF", added.Error())
+		m := errors.ToMap(added)
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, "override", m["key2"])
+		assert.Equal(t, "value3", m["key3"])
+		assert.Equal(t, errors.ToMap(err)["excLineNum"], m["excLineNum"])
+
+		// The original is not modified
+		assert.Equal(t, "value2", errors.ToMap(err)["key2"])
+		assert.NotContains(t, errors.ToMap(err), "key3")
+	})
+
+	t.Run("reuses the stack of errors which have one", func(t *testing.T) {
+		for _, err := range []error{
+			errors.Wrap(io.EOF, "message"),
+			errors.Stack(io.EOF),
+			errors.WrapOpts(io.EOF, "message", errors.WithKind(errors.KindNotFound)),
+		} {
+			added := errors.AddFields(err, errors.Fields{"key1": "value1"})
+			assert.Equal(t, err.Error(), added.Error())
+			assert.True(t, errors.Is(added, io.EOF))
+
+			m := errors.ToMap(added)
+			assert.Equal(t, "value1", m["key1"])
+			assert.Equal(t, errors.ToMap(err)["excLineNum"], m["excLineNum"])
+		}
+	})
+
+	t.Run("wraps foreign errors", func(t *testing.T) {
+		added := errors.AddFields(io.EOF, errors.Fields{"key1": "value1"})
+		assert.Equal(t, "EOF", added.Error())
+		m := errors.ToMap(added)
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, "errors_test.TestAddFields.func3", m["excFuncName"])
+	})
+
+	t.Run("reuses the stack of a foreign error wrapping one", func(t *testing.T) {
+		cause := errors.Stack(io.EOF)
+		added := errors.AddFields(fmt.Errorf("message: %w", cause), errors.Fields{"key1": "value1"})
+		assert.Equal(t, "message: EOF", added.Error())
+
+		m := errors.ToMap(added)
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, errors.ToMap(cause)["excLineNum"], m["excLineNum"])
+
+		// No second stack trace is captured
+		trace, _ := errors.StackOf(cause)
+		actual, _ := errors.StackOf(added)
+		assert.Equal(t, trace, actual)
+		assert.Empty(t, added.(callstack.HasStackTrace).StackTrace())
+	})
+
+	t.Run("nil error returns nil", func(t *testing.T) {
+		assert.Nil(t, errors.AddFields(nil, errors.Fields{"key1": "value1"}))
+	})
+}
+
+func TestFieldsBuilder(t *testing.T) {
+	now := time.Now()
+	err := errors.F().
+		Str("user", "thrawn").
+		Int("attempt", 2).
+		Int64("id", 64).
+		Float64("ratio", 0.5).
+		Bool("retry", true).
+		Dur("elapsed", time.Second).
+		Time("at", now).
+		Any("tags", []string{"a"}).
+		Wrap(io.EOF, "message")
+
+	assert.Equal(t, "message: EOF", err.Error())
+	m := errors.ToMap(err)
+	assert.Equal(t, "thrawn", m["user"])
+	assert.Equal(t, 2, m["attempt"])
+	assert.Equal(t, int64(64), m["id"])
+	assert.Equal(t, 0.5, m["ratio"])
+	assert.Equal(t, true, m["retry"])
+	assert.Equal(t, time.Second, m["elapsed"])
+	assert.Equal(t, now, m["at"])
+	assert.Equal(t, []string{"a"}, m["tags"])
+	assert.Equal(t, "errors_test.TestFieldsBuilder", m["excFuncName"])
+
+	t.Run("Fields", func(t *testing.T) {
+		f := errors.F().Str("key1", "value1").Str("key1", "value2").Int("key2", 2).Fields()
+		assert.Equal(t, errors.Fields{"key1": "value2", "key2": 2}, f)
+		assert.Empty(t, errors.F().Fields())
+	})
+
+	t.Run("many fields", func(t *testing.T) {
+		b := errors.F()
+		expected := errors.Fields{}
+		for i := 0; i < 20; i++ {
+			b.Int(fmt.Sprintf("key%d", i), i)
+			expected[fmt.Sprintf("key%d", i)] = i
+		}
+		b.Int("key0", 100)
+		expected["key0"] = 100
+		assert.Equal(t, expected, b.Fields())
+		assert.Equal(t, map[string]any(expected), errors.FieldsOf(b.Wrap(io.EOF, "message")))
+	})
+
+	t.Run("wrapping methods", func(t *testing.T) {
+		assert.Nil(t, errors.F().Str("key1", "value1").Wrap(nil, "message"))
+		assert.Nil(t, errors.F().Str("key1", "value1").Wrapf(nil, "message %d", 1))
+		assert.Nil(t, errors.F().Str("key1", "value1").Stack(nil))
+
+		err := errors.F().Str("key1", "value1").Wrapf(io.EOF, "message %d", 1)
+		assert.Equal(t, "message 1: EOF", err.Error())
+		assert.True(t, errors.Is(err, io.EOF))
+		err = errors.F().Str("key1", "value1").Stack(io.EOF)
+		assert.Equal(t, "EOF", err.Error())
+		err = errors.F().Str("key1", "value1").Errorf("failed %d", 1)
+		assert.Equal(t, "failed 1", err.Error())
+		err = errors.F().Str("key1", "value1").Error("failed")
+		assert.Equal(t, "failed", err.Error())
+		m := errors.ToMap(err)
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, "errors_test.TestFieldsBuilder.func3", m["excFuncName"])
+	})
+}
+
+func BenchmarkFieldsBuilder(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = errors.F().Str("user", "thrawn").Int("attempt", 2).Dur("elapsed", time.Second).Wrap(io.EOF, "message")
+	}
+}
+
+func TestFieldsMerge(t *testing.T) {
+	a := errors.Fields{"key1": "a", "key2": "a"}
+	b := errors.Fields{"key2": "b", "key3": "b"}
+	c := errors.Fields{"key3": "c"}
+
+	assert.Equal(t, errors.Fields{"key1": "a", "key2": "b", "key3": "c"}, a.Merge(b, c))
+	assert.Equal(t, errors.Fields{"key1": "a", "key2": "a", "key3": "c"}, b.Merge(a, c).Merge(a))
+	assert.Equal(t, errors.Fields{"key1": "a", "key2": "a"}, a)
+	assert.Equal(t, errors.Fields{}, errors.Fields(nil).Merge())
+}
+
+func TestMergeFields(t *testing.T) {
+	a := map[string]any{"key1": "a", "key2": "a"}
+	b := errors.Fields{"key2": "b"}
+
+	assert.Equal(t, errors.Fields{"key1": "a", "key2": "b"}, errors.MergeFields(a, b))
+	assert.Equal(t, errors.Fields{"key1": "a", "key2": "a"}, errors.MergeFields(b, a))
+	assert.Equal(t, errors.Fields{}, errors.MergeFields())
+}
+
+func TestWrapKV(t *testing.T) {
+	err := errors.WrapKV(io.EOF, "message", "user_id", 42, "region", "us-east")
+	assert.Equal(t, "message: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, 42, m["user_id"])
+	assert.Equal(t, "us-east", m["region"])
+	assert.Equal(t, "errors_test.TestWrapKV", m["excFuncName"])
+
+	t.Run("odd number of arguments", func(t *testing.T) {
+		m := errors.ToMap(errors.WrapKV(io.EOF, "message", "key1", "value1", "key2"))
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, "!MISSING", m["key2"])
+	})
+
+	t.Run("non string keys", func(t *testing.T) {
+		m := errors.ToMap(errors.WrapKV(io.EOF, "message", 1, "value1"))
+		assert.Equal(t, "value1", m["1"])
+	})
+
+	t.Run("duplicate keys", func(t *testing.T) {
+		err := errors.WrapKV(io.EOF, "message", "key1", 1, "key2", 2, "key1", 3)
+		assert.Equal(t, "message: EOF (key1=3, key2=2)", fmt.Sprintf("%+v", err))
+		assert.Equal(t, 3, errors.ToMap(err)["key1"])
+	})
+
+	t.Run("many fields", func(t *testing.T) {
+		var kv []any
+		for i := 0; i < 20; i++ {
+			kv = append(kv, fmt.Sprintf("key%d", i), i)
+		}
+		m := errors.ToMap(errors.WrapKV(io.EOF, "message", kv...))
+		assert.Equal(t, 0, m["key0"])
+		assert.Equal(t, 19, m["key19"])
+	})
+
+	t.Run("slog.Attr arguments", func(t *testing.T) {
+		m := errors.ToMap(errors.WrapKV(io.EOF, "message", slog.String("key1", "value1"), "key2", "value2"))
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, "value2", m["key2"])
+	})
+
+	t.Run("nil error returns nil", func(t *testing.T) {
+		assert.Nil(t, errors.WrapKV(nil, "message", "key1", "value1"))
+	})
+}
+
+func TestFieldsFromAttrs(t *testing.T) {
+	f := errors.FieldsFromAttrs(
+		slog.String("user", "thrawn"),
+		slog.Int("attempt", 2),
+		slog.Group("req", slog.String("id", "abc"), slog.Group("", slog.Bool("retry", true))),
+	)
+	assert.Equal(t, errors.Fields{
+		"user":      "thrawn",
+		"attempt":   int64(2),
+		"req.id":    "abc",
+		"req.retry": true,
+	}, f)
+
+	m := errors.ToMap(f.Wrap(io.EOF, "message"))
+	assert.Equal(t, "thrawn", m["user"])
+}
+
+func TestToMapOpts(t *testing.T) {
+	err := errors.Fields{"key1": "value1", "key2": "value2", "key3": "value3"}.Wrap(io.EOF, "message")
+	err = errors.Wrap(err, "top")
+
+	t.Run("defaults match ToMap()", func(t *testing.T) {
+		assert.Equal(t, errors.ToMap(err), errors.ToMapOpts(err, errors.ToMapOptions{}))
+	})
+
+	t.Run("IncludeStack", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeStack: true})
+		assert.Regexp(t, `fields_test.go:\d+`, m["excStack"])
+	})
+
+	t.Run("IncludeFrames", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeFrames: true})
+		frames, ok := m["excFrames"].([]callstack.FrameRecord)
+		require.True(t, ok)
+		require.NotEmpty(t, frames)
+		assert.Equal(t, "errors_test.TestToMapOpts", frames[0].Func)
+		assert.Equal(t, m["excFileName"], frames[0].File)
+		assert.Equal(t, m["excLineNum"], frames[0].Line)
+	})
+
+	t.Run("IncludeRawStack", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeRawStack: true})
+		raw, ok := m["excRawStack"].(callstack.RawStack)
+		require.True(t, ok)
+		assert.NotEmpty(t, raw.Offsets)
+	})
+
+	t.Run("KeyPrefix", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{KeyPrefix: "err_"})
+		assert.Equal(t, "value1", m["err_key1"])
+		assert.Equal(t, "top: message: EOF", m["err_excValue"])
+		assert.NotContains(t, m, "key1")
+		assert.Len(t, m, 8)
+	})
+
+	t.Run("MaxFields", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{MaxFields: 2})
+		assert.Equal(t, "value1", m["key1"])
+		assert.Equal(t, "value2", m["key2"])
+		assert.NotContains(t, m, "key3")
+		assert.Equal(t, "top: message: EOF", m["excValue"])
+	})
+
+	t.Run("IncludeChain", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeChain: true})
+		assert.Equal(t, []string{"*errors.wrappedError", "*errors.fields", "*errors.errorString"}, m["excChain"])
+	})
+
+	t.Run("IncludeRootType", func(t *testing.T) {
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeRootType: true})
+		assert.Equal(t, "*errors.fields", m["excType"])
+		assert.Equal(t, "*errors.errorString", m["excRootType"])
+		assert.NotContains(t, errors.ToMap(err), "excRootType")
+	})
+
+	t.Run("IncludeMessages", func(t *testing.T) {
+		err := errors.New("root")
+		err = errors.Wrap(err, "last")
+		err = errors.Stack(err)
+		err = fmt.Errorf("second: %w", err)
+		err = errors.Wrap(err, "first")
+
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeMessages: true})
+		assert.Equal(t, []string{"first", "second", "last", "root"}, m["excMessages"])
+
+		// Text following %w is kept with the message of the formatted error
+		err = errors.Wrap(errors.Errorf("read %w: retrying after EOF", io.EOF), "while syncing")
+		m = errors.ToMapOpts(err, errors.ToMapOptions{IncludeMessages: true})
+		assert.Equal(t, []string{"while syncing", "read EOF: retrying after EOF", "EOF"}, m["excMessages"])
+	})
+
+	t.Run("IncludeBuildInfo", func(t *testing.T) {
+		// Test binaries do not embed VCS information, but do report the main module version
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeBuildInfo: true})
+		assert.Contains(t, m, "excBuildVersion")
+	})
+
+	t.Run("IncludeHost", func(t *testing.T) {
+		hostname, _ := os.Hostname()
+		m := errors.ToMapOpts(err, errors.ToMapOptions{IncludeHost: true})
+		assert.Equal(t, hostname, m["excHostname"])
+		assert.Equal(t, os.Getpid(), m["excPID"])
+	})
+
+	t.Run("DefaultToMapOptions", func(t *testing.T) {
+		errors.DefaultToMapOptions.IncludeChain = true
+		defer func() { errors.DefaultToMapOptions = errors.ToMapOptions{} }()
+		assert.Contains(t, errors.ToMap(err), "excChain")
+	})
+
+	t.Run("nil error returns nil", func(t *testing.T) {
+		assert.Nil(t, errors.ToMapOpts(nil, errors.ToMapOptions{IncludeStack: true}))
+	})
+}
+
+func TestLogrusEntry(t *testing.T) {
+	err := errors.Fields{"key1": "value1", "key2": "value2"}.Wrap(io.EOF, "message")
+
+	b := bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(&b)
+
+	errors.LogrusEntry(logger, err, errors.Fields{"key2": "extra", "tid": 1}).Error("test logrus entry")
+	assert.Contains(t, b.String(), "test logrus entry")
+	assert.Contains(t, b.String(), `error="message: EOF"`)
+	assert.Contains(t, b.String(), "key1=value1")
+	assert.Contains(t, b.String(), "key2=extra")
+	assert.Contains(t, b.String(), "tid=1")
+	assert.Contains(t, b.String(), "excFuncName=errors_test.TestLogrusEntry")
+
+	t.Run("accepts an entry", func(t *testing.T) {
+		b.Reset()
+		entry := errors.LogrusEntry(logger.WithField("svc", "api"), err, nil)
+		entry.Info("test")
+		assert.Contains(t, b.String(), "svc=api")
+		assert.Contains(t, b.String(), "key1=value1")
+	})
+
+	t.Run("nil error", func(t *testing.T) {
+		entry := errors.LogrusEntry(logger, nil, errors.Fields{"tid": 1})
+		assert.Equal(t, logrus.Fields{"tid": 1}, entry.Data)
+	})
+}
+
+func TestWrapElapsed(t *testing.T) {
+	start := time.Now().Add(-time.Second)
+
+	err := errors.WrapElapsed(io.EOF, start, "message")
+	assert.Equal(t, "message: EOF", err.Error())
+	m := errors.ToMap(err)
+	assert.GreaterOrEqual(t, m["elapsed"], time.Second)
+	assert.Equal(t, "errors_test.TestWrapElapsed", m["excFuncName"])
+
+	assert.Nil(t, errors.WrapElapsed(nil, start, "message"))
+}
+
+func TestTimer(t *testing.T) {
+	start := time.Now().Add(-time.Second)
+
+	err := errors.Timer(start).Str("key1", "value1").Wrap(io.EOF, "message")
+	m := errors.ToMap(err)
+	assert.GreaterOrEqual(t, m["elapsed"], time.Second)
+	assert.Equal(t, "value1", m["key1"])
+}
+
+func TestSanitizeKeys(t *testing.T) {
+	assert.Equal(t, "user_name", errors.SanitizeKey("user name"))
+	assert.Equal(t, "a_b__c_", errors.SanitizeKey(`a=b "c"`))
+	assert.Equal(t, "line_break", errors.SanitizeKey("line\nbreak"))
+	assert.Equal(t, "_", errors.SanitizeKey(""))
+
+	errors.SanitizeKeys = true
+	defer func() { errors.SanitizeKeys = false }()
+
+	err := errors.Fields{"user name": "thrawn"}.Wrap(io.EOF, "message")
+	assert.Equal(t, "message: EOF (user_name=thrawn)", fmt.Sprintf("%+v", err))
+	assert.Equal(t, "thrawn", errors.ToLogrus(err)["user_name"])
+	assert.Equal(t, "thrawn", errors.ToMap(err)["user name"])
+}
+
+func TestEscapeControlChars(t *testing.T) {
+	err := errors.Fields{"body": "line1\nlevel=error msg=forged\t\x00"}.Wrap(io.EOF, "message")
+	assert.Equal(t, `message: EOF (body="line1\nlevel=error msg=forged\t\x00")`, fmt.Sprintf("%+v", err))
+
+	errors.EscapeControlChars = false
+	defer func() { errors.EscapeControlChars = true }()
+	assert.Equal(t, "message: EOF (body=\"line1\nlevel=error msg=forged\t\x00\")", fmt.Sprintf("%+v", err))
+
+	err = errors.Fields{"path": `C:\temp`}.Wrap(io.EOF, "message")
+	assert.Equal(t, `message: EOF (path="C:\\temp")`, fmt.Sprintf("%+v", err))
+}
+
+func TestFormatFieldsQuotesValues(t *testing.T) {
+	for _, tt := range []struct {
+		value    any
+		expected string
+	}{
+		{value: "value1", expected: "key=value1"},
+		{value: "some value", expected: `key="some value"`},
+		{value: "a,b", expected: `key="a,b"`},
+		{value: "a=b", expected: `key="a=b"`},
+		{value: `say "hi"`, expected: `key="say \"hi\""`},
+		{value: `C:\temp`, expected: `key="C:\\temp"`},
+		{value: `a\" b`, expected: `key="a\\\" b"`},
+		{value: "a\nb", expected: `key="a\nb"`},
+		{value: 10, expected: "key=10"},
+	} {
+		err := errors.Fields{"key": tt.value}.Wrap(io.EOF, "message")
+		assert.Equal(t, "message: EOF ("+tt.expected+")", fmt.Sprintf("%+v", err))
+	}
+}
+
+func TestFieldsOf(t *testing.T) {
+	err := errors.Fields{"key1": "value1"}.Wrap(errors.Fields{"key2": "value2"}.Wrap(io.EOF, "inner"), "outer")
+	assert.Equal(t, map[string]any{"key1": "value1", "key2": "value2"}, errors.FieldsOf(err))
+
+	assert.Nil(t, errors.FieldsOf(errors.Wrap(io.EOF, "no fields")))
+	assert.Nil(t, errors.FieldsOf(io.EOF))
+	assert.Nil(t, errors.FieldsOf(nil))
+}
+
+func TestToMapCollisions(t *testing.T) {
+	err := errors.Fields{"id": "inner", "shared": "same"}.Wrap(io.EOF, "inner")
+	err = errors.Fields{"id": "middle", "shared": "same"}.Wrap(err, "middle")
+	err = errors.WrapOpts(err, "outer", errors.WithFieldsOpt(errors.Fields{"id": "outer"}))
+
+	m := errors.ToMapOpts(err, errors.ToMapOptions{})
+	assert.Equal(t, "inner", m["id"])
+	assert.NotContains(t, m, "id#2")
+
+	m = errors.ToMapOpts(err, errors.ToMapOptions{Collisions: errors.CollisionSuffix})
+	assert.Equal(t, "inner", m["id"])
+	assert.Equal(t, "middle", m["id#2"])
+	assert.Equal(t, "outer", m["id#3"])
+	assert.Equal(t, "same", m["shared"])
+	assert.NotContains(t, m, "shared#2")
+
+	m = errors.ToMapOpts(err, errors.ToMapOptions{Collisions: errors.CollisionSlice})
+	assert.Equal(t, []any{"inner", "middle", "outer"}, m["id"])
+	assert.Equal(t, "same", m["shared"])
+
+	// Branches of joined errors collide with each other
+	joined := errors.Join(errors.WrapKV(io.EOF, "first", "id", 1), errors.WrapKV(io.EOF, "second", "id", 2))
+	m = errors.ToMapOpts(errors.Wrap(joined, "batch"), errors.ToMapOptions{Collisions: errors.CollisionSlice})
+	assert.ElementsMatch(t, []any{1, 2}, m["id"])
+}
+
+func TestToMapCollisionsRedacted(t *testing.T) {
+	removeHash := errors.RegisterRedactor(errors.HashRedactor([]byte("salt"), "email"))
+	defer removeHash()
+	remove := errors.RegisterRedactor(func(key string, value any) (any, bool) {
+		if s, ok := value.(string); ok && strings.Contains(s, "@") {
+			return errors.Redacted, true
+		}
+		return nil, false
+	})
+	defer remove()
+
+	err := errors.Fields{"email": "inner@example.com", "to": "inner@example.com"}.Wrap(io.EOF, "inner")
+	err = errors.Fields{"email": "outer@example.com", "to": "outer@example.com"}.Wrap(err, "outer")
+
+	m := errors.ToMapOpts(err, errors.ToMapOptions{Collisions: errors.CollisionSuffix})
+	assert.NotContains(t, m["email"], "@")
+	assert.NotContains(t, m["email#2"], "@")
+	assert.NotEqual(t, m["email"], m["email#2"])
+	assert.Equal(t, errors.Redacted, m["to"])
+	assert.NotContains(t, m, "to#2")
+
+	m = errors.ToMapOpts(err, errors.ToMapOptions{Collisions: errors.CollisionSlice})
+	assert.Len(t, m["email"], 2)
+	assert.Equal(t, errors.Redacted, m["to"])
+}
+
+func TestToMapExcTypeMatchesFormat(t *testing.T) {
+	for _, cause := range []error{io.EOF, &ErrTest{Msg: "query error"}, errors.New("plain")} {
+		m := errors.ToMap(errors.Wrap(cause, "message"))
+		assert.Equal(t, fmt.Sprintf("%T", cause), m["excType"])
+	}
+	// An error without a wrapped error reports <nil> as %T does
+	assert.Equal(t, "<nil>", errors.ToMap(&ErrTest{Msg: "query error"})["excType"])
+}
+
+func BenchmarkToMap(b *testing.B) {
+	err := errors.Fields{"key": "value"}.Wrap(io.EOF, "message")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = errors.ToMap(err)
+	}
+}
+
+func TestHasFieldsRepeatedWrappers(t *testing.T) {
+	base := errors.Fields{"key": "base", "shared": "base"}.Wrap(io.EOF, "while reading")
+
+	// Every attempt wraps the same error, which must only be merged once
+	var attempts []error
+	for i := 0; i < 3; i++ {
+		attempts = append(attempts, errors.Fields{"attempt": i, "shared": "attempt"}.Wrap(base, "attempt"))
+	}
+	err := errors.Fields{"batch": 1}.WrapAll("while retrying", attempts...)
+
+	assert.Equal(t, map[string]any{
+		"key":     "base",
+		"shared":  "base",
+		"attempt": 0,
+		"batch":   1,
+	}, errors.FieldsOf(err))
+
+	// Joined by the standard library, every branch is merged
+	joined := errors.Fields{"outer": true}.Wrap(errors.Join(attempts[2], base, base), "while retrying")
+	assert.Equal(t, map[string]any{
+		"key":     "base",
+		"shared":  "base",
+		"attempt": 2,
+		"outer":   true,
+	}, errors.FieldsOf(joined))
+}
+
+func BenchmarkHasFieldsRetryLoop(b *testing.B) {
+	err := errors.Fields{"key": "value"}.Wrap(io.EOF, "while reading")
+	var attempts []error
+	for i := 0; i < 100; i++ {
+		attempts = append(attempts, errors.Fields{"attempt": i}.Wrap(err, "attempt"))
+	}
+	err = errors.Fields{"batch": 1}.WrapAll("while retrying", attempts...)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = errors.FieldsOf(err)
+	}
+}
@@ -0,0 +1,110 @@
+package errors
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// maxSmallFields is the number of fields a fieldSet stores in a slice before
+// falling back to a map.
+const maxSmallFields = 8
+
+type field struct {
+	key   string
+	value any
+}
+
+// fieldSet stores the fields attached by the fields wrapper. Small sets built from
+// key/value pairs, which are by far the most common, are stored in a slice to avoid
+// allocating a map. Larger sets, and those provided by the caller as a Fields map
+// literal, are stored as a map.
+type fieldSet struct {
+	small []field
+	large Fields
+}
+
+// setOf returns a fieldSet which uses f as its storage
+func setOf(f Fields) fieldSet {
+	return fieldSet{large: f}
+}
+
+// kvToSet is identical to kvToFields() but avoids allocating a map for small sets
+func kvToSet(kv []any) fieldSet {
+	if len(kv) > 2*maxSmallFields {
+		return setOf(kvToFields(kv))
+	}
+	var s fieldSet
+	s.small = make([]field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i++ {
+		if _, ok := kv[i].(slog.Attr); ok {
+			// Attributes may be groups which expand to any number of fields
+			return setOf(kvToFields(kv))
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		if i+1 == len(kv) {
+			s.add(key, "!MISSING")
+			break
+		}
+		i++
+		s.add(key, kv[i])
+	}
+	return s
+}
+
+// add sets the key to value, replacing the value of an existing key. It must only
+// be called while building the set, as the slice is shared by copies of the set.
+func (s *fieldSet) add(key string, value any) {
+	for i := range s.small {
+		if s.small[i].key == key {
+			s.small[i].value = value
+			return
+		}
+	}
+	s.small = append(s.small, field{key: key, value: value})
+}
+
+func (s fieldSet) len() int {
+	return len(s.small) + len(s.large)
+}
+
+// each calls fn for each of the fields in the set
+func (s fieldSet) each(fn func(key string, value any)) {
+	for _, f := range s.small {
+		fn(f.key, f.value)
+	}
+	for key, value := range s.large {
+		fn(key, value)
+	}
+}
+
+// toMap returns the fields as a map, which must not be modified by the caller
+func (s fieldSet) toMap() Fields {
+	if len(s.small) == 0 {
+		return s.large
+	}
+	result := make(Fields, s.len())
+	s.each(func(key string, value any) {
+		result[key] = value
+	})
+	return result
+}
+
+// merge returns a new set containing the fields of s and f, where f takes precedence
+func (s fieldSet) merge(f Fields) fieldSet {
+	return setOf(MergeFields(s.toMap(), f))
+}
+
+// clone returns a copy of the set which shares no storage with s
+func (s fieldSet) clone() fieldSet {
+	var c fieldSet
+	if len(s.small) != 0 {
+		c.small = append([]field(nil), s.small...)
+	}
+	if s.large != nil {
+		c.large = Fields(nil).Merge(s.large)
+	}
+	return c
+}
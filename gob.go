@@ -0,0 +1,259 @@
+package errors
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"time"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// The wrapper types are registered with gob such that an error returned by this
+// package can be assigned to an interface value sent using encoding/gob or net/rpc.
+// Each wrapper is encoded as the Envelope of the entire chain, as the wrapped errors
+// could be of types unknown to gob. The decoded error reports the same message,
+// fields, code, kind, id and status, but has no stack trace as the program counters
+// are only meaningful within the process which captured them. The original frames
+// are available from the Envelope returned by ToEnvelope() on the decoded error.
+func init() {
+	gob.Register(&wrappedError{})
+	gob.Register(&formattedError{})
+	gob.Register(&formattedErrors{})
+	gob.Register(&formattedMsg{})
+	gob.Register(&fields{})
+	gob.Register(&fieldsJoin{})
+	gob.Register(&stack{})
+	gob.Register(&annotation{})
+	gob.Register(&annotated{})
+	gob.Register(&annotatedStack{})
+	gob.Register(&backoff{})
+	gob.Register(&breadcrumbs{})
+	gob.Register(&decodedError{})
+}
+
+// decodedError is the cause of a wrapper decoded by gob, it carries
+// the Envelope of the error which was encoded.
+type decodedError struct {
+	env *Envelope
+}
+
+func (d *decodedError) Error() string {
+	return limitMsg(d.env.Message)
+}
+
+func (d *decodedError) Code() string {
+	return d.env.Code
+}
+
+func (d *decodedError) Is(target error) bool {
+	return isCode(d.env.Code, target)
+}
+
+func (d *decodedError) Status() int {
+	return d.env.Status
+}
+
+func (d *decodedError) Kind() Kind {
+	return d.env.Kind
+}
+
+func (d *decodedError) ID() string {
+	return d.env.ID
+}
+
+func (d *decodedError) HasFields() map[string]any {
+	result := make(map[string]any, len(d.env.Fields))
+	for key, value := range d.env.Fields {
+		result[key] = value
+	}
+	return result
+}
+
+func (d *decodedError) ownFields() map[string]any { return d.env.Fields }
+
+func (d *decodedError) message() string { return d.env.Message }
+
+func (d *decodedError) clone() error {
+	env := *d.env
+	env.Fields = Fields(nil).Merge(d.env.Fields)
+	return &decodedError{env: &env}
+}
+
+func (d *decodedError) stripStack() error {
+	env := *d.env
+	env.Frames = nil
+	return &decodedError{env: &env}
+}
+
+func (d *decodedError) GobEncode() ([]byte, error) {
+	return json.Marshal(d.env)
+}
+
+func (d *decodedError) GobDecode(b []byte) error {
+	env, err := ParseJSON(b)
+	if err != nil {
+		return err
+	}
+	d.env = env
+	return nil
+}
+
+// gobEncode returns the encoding shared by all the wrapper types
+func gobEncode(err error) ([]byte, error) {
+	return json.Marshal(ToEnvelope(err).Exportable())
+}
+
+// gobDecode returns the decoded cause for the wrapper types
+func gobDecode(b []byte) (*decodedError, error) {
+	d := &decodedError{}
+	if err := d.GobDecode(b); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (e *wrappedError) GobEncode() ([]byte, error) { return gobEncode(e) }
+
+func (e *wrappedError) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*e = wrappedError{msg: NoMsg, wrapped: d}
+	return nil
+}
+
+func (e *formattedError) GobEncode() ([]byte, error) { return gobEncode(e) }
+
+func (e *formattedError) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*e = formattedError{msg: d.env.Message, wrapped: d}
+	return nil
+}
+
+func (e *formattedMsg) GobEncode() ([]byte, error) { return gobEncode(e) }
+
+func (e *formattedMsg) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*e = formattedMsg{msg: d.env.Message}
+	return nil
+}
+
+func (e *formattedErrors) GobEncode() ([]byte, error) { return gobEncode(e) }
+
+func (e *formattedErrors) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*e = formattedErrors{msg: d.env.Message, wrapped: []error{d}}
+	return nil
+}
+
+func (c *fields) GobEncode() ([]byte, error) { return gobEncode(c) }
+
+func (c *fields) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*c = fields{msg: NoMsg, wrapped: d}
+	return nil
+}
+
+func (c *fieldsJoin) GobEncode() ([]byte, error) { return gobEncode(c) }
+
+func (c *fieldsJoin) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*c = fieldsJoin{msg: NoMsg, wrapped: []error{d}}
+	return nil
+}
+
+func (w *stack) GobEncode() ([]byte, error) { return gobEncode(w) }
+
+func (w *stack) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*w = stack{error: d}
+	return nil
+}
+
+func (e *annotation) GobEncode() ([]byte, error) { return gobEncode(e) }
+
+func (e *annotation) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*e = annotation{msg: NoMsg, wrapped: d}
+	return nil
+}
+
+func (a *annotated) GobEncode() ([]byte, error) { return gobEncode(a) }
+
+func (a *annotated) GobDecode(b []byte) error {
+	d, err := gobDecode(b)
+	if err != nil {
+		return err
+	}
+	*a = annotated{
+		code:    d.env.Code,
+		status:  d.env.Status,
+		kind:    d.env.Kind,
+		id:      d.env.ID,
+		msg:     NoMsg,
+		wrapped: d,
+	}
+	return nil
+}
+
+func (a *annotatedStack) GobEncode() ([]byte, error) { return gobEncode(a) }
+
+func (a *annotatedStack) GobDecode(b []byte) error {
+	if err := a.annotated.GobDecode(b); err != nil {
+		return err
+	}
+	a.Trace = callstack.Trace{}
+	return nil
+}
+
+func (b *backoff) GobEncode() ([]byte, error) { return gobEncode(b) }
+
+func (b *backoff) GobDecode(data []byte) error {
+	d, err := gobDecode(data)
+	if err != nil {
+		return err
+	}
+	*b = backoff{wrapped: d}
+	// JSON decodes numbers as float64, and the backoff as nanoseconds
+	if next, ok := d.env.Fields["retry.backoff"].(float64); ok {
+		b.next = time.Duration(next)
+	}
+	if attempt, ok := d.env.Fields["retry.attempt"].(float64); ok {
+		b.attempt = int(attempt)
+	}
+	return nil
+}
+
+func (b *breadcrumbs) GobEncode() ([]byte, error) { return gobEncode(b) }
+
+func (b *breadcrumbs) GobDecode(data []byte) error {
+	d, err := gobDecode(data)
+	if err != nil {
+		return err
+	}
+	// The breadcrumbs are reported by the decoded cause
+	*b = breadcrumbs{wrapped: d}
+	return nil
+}
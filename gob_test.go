@@ -0,0 +1,86 @@
+package errors_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gobReply struct {
+	Result string
+	Err    error
+}
+
+func gobRoundTrip(t *testing.T, err error) error {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(gobReply{Err: err}))
+
+	var decoded gobReply
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	require.NotNil(t, decoded.Err)
+	return decoded.Err
+}
+
+func TestGobWrappers(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+	}{
+		{name: "Wrap", err: errors.Wrap(io.EOF, "while reading")},
+		{name: "Errorf", err: errors.Errorf("while reading: %w", io.EOF)},
+		{name: "Errorf multiple", err: errors.Errorf("%w and %w", io.EOF, io.ErrClosedPipe)},
+		{name: "Fields", err: errors.Fields{"key1": "value1"}.Wrap(io.EOF, "while reading")},
+		{name: "Stack", err: errors.Stack(io.EOF)},
+		{name: "WrapOpts", err: errors.WrapOpts(io.EOF, "while reading", errors.NoStack())},
+		{name: "WrapOpts stack", err: errors.WrapOpts(io.EOF, "while reading")},
+		{name: "WithBackoff", err: errors.WithBackoff(io.EOF, time.Second, 2)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := gobRoundTrip(t, tt.err)
+			assert.Equal(t, tt.err.Error(), decoded.Error())
+			assert.Equal(t, errors.ToMap(tt.err)["key1"], errors.ToMap(decoded)["key1"])
+
+			// Decoded errors can be sent again
+			assert.Equal(t, tt.err.Error(), gobRoundTrip(t, decoded).Error())
+		})
+	}
+}
+
+func TestGobPreservesDetails(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while fetching",
+		errors.WithCode("domain.not_found"),
+		errors.WithKind(errors.Kind("not_found")),
+		errors.WithStatus(404),
+		errors.WithFieldsOpt(errors.Fields{"domain.id": "example.com"}),
+	)
+	decoded := gobRoundTrip(t, err)
+
+	assert.Equal(t, "domain.not_found", errors.CodeOf(decoded))
+
+	env := errors.ToEnvelope(decoded)
+	assert.Equal(t, "*errors.errorString", env.Type)
+	assert.Equal(t, errors.Kind("not_found"), env.Kind)
+	assert.Equal(t, 404, env.Status)
+	assert.Equal(t, "example.com", env.Fields["domain.id"])
+	require.NotEmpty(t, env.Frames)
+	assert.Equal(t, "errors_test.TestGobPreservesDetails", env.Frames[0].Func)
+
+	next, attempt, ok := errors.BackoffOf(gobRoundTrip(t, errors.WithBackoff(io.EOF, time.Second, 2)))
+	require.True(t, ok)
+	assert.Equal(t, time.Second, next)
+	assert.Equal(t, 2, attempt)
+}
+
+func TestGobDecodedClone(t *testing.T) {
+	decoded := gobRoundTrip(t, errors.Fields{"key": "value"}.Wrap(io.EOF, "while reading"))
+	c := errors.Clone(decoded)
+	assert.Equal(t, errors.FieldsOf(decoded), errors.FieldsOf(c))
+	assert.Equal(t, decoded.Error(), c.Error())
+}
@@ -0,0 +1,127 @@
+package errors
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// wrapHooks are the hooks registered using OnWrap()
+var wrapHooks hookList
+
+// hookList is a list of functions called with an error, which can be read
+// without holding a lock as it is replaced with a modified copy on every change.
+type hookList struct {
+	mu    sync.Mutex
+	hooks atomic.Pointer[[]*hook]
+}
+
+type hook struct {
+	fn func(error)
+}
+
+// add registers fn and returns a function which removes it
+func (l *hookList) add(fn func(error)) (remove func()) {
+	h := &hook{fn: fn}
+	l.update(func(hooks []*hook) []*hook {
+		return append(hooks, h)
+	})
+	return func() {
+		l.update(func(hooks []*hook) []*hook {
+			result := hooks[:0]
+			for _, hook := range hooks {
+				if hook != h {
+					result = append(result, hook)
+				}
+			}
+			return result
+		})
+	}
+}
+
+// update replaces the registered hooks with a modified copy
+func (l *hookList) update(modify func([]*hook) []*hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var hooks []*hook
+	if current := l.hooks.Load(); current != nil {
+		hooks = append(hooks, *current...)
+	}
+	hooks = modify(hooks)
+	if len(hooks) == 0 {
+		l.hooks.Store(nil)
+		return
+	}
+	l.hooks.Store(&hooks)
+}
+
+// load returns the registered hooks, nil if there are none
+func (l *hookList) load() *[]*hook {
+	return l.hooks.Load()
+}
+
+// without returns a copy of entries with entry removed, used by the registries which
+// return a function to remove what was registered.
+func without[T any](entries []*T, entry *T) []*T {
+	result := make([]*T, 0, len(entries))
+	for _, e := range entries {
+		if e != entry {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// put sets key to value in m and returns a function which restores the previous value
+// of key, used by the registries which map keys to values. The caller must hold mu,
+// which is taken by the returned function.
+func put[K comparable, V any](mu sync.Locker, m map[K]V, key K, value V) (remove func()) {
+	prev, hadPrev := m[key]
+	m[key] = value
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if hadPrev {
+			m[key] = prev
+			return
+		}
+		delete(m, key)
+	}
+}
+
+// OnWrap registers fn to be called with every failure when it is first wrapped by an
+// error from this package which captures a stack trace. Wrapping an error which already
+// has a stack trace does not call fn again, such that each failure is observed once.
+// Hooks are called synchronously by the constructor, and must not block.
+// OnWrap returns a function which removes the hook.
+//
+//	remove := errors.OnWrap(reporter.Report)
+//	defer remove()
+func OnWrap(fn func(err error)) (remove func()) {
+	return wrapHooks.add(fn)
+}
+
+// observeWrap is called by every constructor which wraps an error with a stack trace
+func observeWrap[T error](err T) T {
+	r, hooks := activeRecorder.Load(), wrapHooks.load()
+	if r == nil && hooks == nil {
+		return err
+	}
+
+	// Only observe the first wrap of a failure
+	var stack callstack.HasStackTrace
+	if cause := Unwrap(err); cause != nil && As(cause, &stack) {
+		return err
+	}
+
+	if r != nil {
+		r.record(err)
+	}
+	if hooks != nil {
+		for _, h := range *hooks {
+			h.fn(err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package errors
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	hostInfoOnce   sync.Once
+	hostInfoFields map[string]any
+	containerIDRe  = regexp.MustCompile(`[0-9a-f]{64}`)
+)
+
+// hostInfo returns the hostname, pid and if available the container ID of the
+// current process, which are collected once as they do not change.
+func hostInfo() map[string]any {
+	hostInfoOnce.Do(func() {
+		hostInfoFields = map[string]any{
+			"excPID": os.Getpid(),
+		}
+		if hostname, err := os.Hostname(); err == nil {
+			hostInfoFields["excHostname"] = hostname
+		}
+		if id := containerID(); id != "" {
+			hostInfoFields["excContainerID"] = id
+		}
+	})
+	return hostInfoFields
+}
+
+// containerID returns the ID of the container the process is running in by
+// inspecting the cgroup (cgroup v1) and mount (cgroup v2) information of the process.
+// Returns an empty string if not running in a container or not on linux.
+func containerID() string {
+	if id := findContainerID("/proc/self/cgroup", ""); id != "" {
+		return id
+	}
+	return findContainerID("/proc/self/mountinfo", "/containers/")
+}
+
+func findContainerID(fileName, contains string) string {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, contains) {
+			continue
+		}
+		if id := containerIDRe.FindString(line); id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package errors
+
+import "github.com/mailgun/errors/callstack"
+
+// Key is a typed field key, such that frequently used fields can be attached and
+// retrieved without typos in the key or type assertions on the value. Values are
+// attached as regular fields under the name of the key, so they are included by
+// ToMap() and HasFields() like any other field.
+//
+//	var UserID = errors.NewKey[int64]("user.id")
+//
+//	err = errors.Set(err, UserID, user.ID)
+//	...
+//	if id, ok := errors.Get(err, UserID); ok {
+//		...
+//	}
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a Key which attaches fields under name
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
+}
+
+// Name returns the field key the value is attached under
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Set returns a new error wrapping err with the value attached under the name of the
+// key. No message is added, and the stack trace of err is used if it has one, otherwise
+// a stack trace is captured at the point Set is called. If err is nil, Set returns nil.
+func Set[T any](err error, k Key[T], value T) error {
+	if err == nil {
+		return nil
+	}
+	f := WithFieldsOpt(Fields{k.name: value})
+	var s callstack.HasStackTrace
+	if As(err, &s) {
+		return WrapOpts(err, NoMsg, f, NoStack())
+	}
+	return WrapOpts(err, NoMsg, f, Skip(1))
+}
+
+// Get returns the value attached under the name of the key in err's chain. As with
+// ToMap(), the value attached closest to the cause wins. If no value is found, or
+// the value is not of type T, it returns false.
+func Get[T any](err error, k Key[T]) (T, bool) {
+	var f HasFields
+	if !As(err, &f) {
+		var zero T
+		return zero, false
+	}
+	value, ok := f.HasFields()[k.name].(T)
+	return value, ok
+}
@@ -0,0 +1,69 @@
+package errors_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	userID = errors.NewKey[int64]("user.id")
+	domain = errors.NewKey[string]("domain")
+)
+
+func TestKey(t *testing.T) {
+	err := errors.Set(errors.Wrap(io.EOF, "while fetching user"), userID, 42)
+	err = errors.Set(err, domain, "example.com")
+
+	assert.Equal(t, "while fetching user: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	id, ok := errors.Get(err, userID)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), id)
+
+	name, ok := errors.Get(err, domain)
+	assert.True(t, ok)
+	assert.Equal(t, "example.com", name)
+
+	m := errors.ToMap(err)
+	assert.Equal(t, int64(42), m["user.id"])
+	assert.Equal(t, "errors_test.TestKey", m["excFuncName"])
+	assert.Equal(t, "user.id", userID.Name())
+
+	// The value attached closest to the cause wins
+	id, _ = errors.Get(errors.Set(err, userID, 7), userID)
+	assert.Equal(t, int64(42), id)
+}
+
+func TestKeyStackTrace(t *testing.T) {
+	wrapped := errors.Wrap(io.EOF, "while fetching user")
+	err := errors.Set(wrapped, userID, 42)
+
+	var expected, actual callstack.HasStackTrace
+	assert.True(t, errors.As(wrapped, &expected))
+	assert.True(t, errors.As(err, &actual))
+	assert.Equal(t, expected.StackTrace(), actual.StackTrace())
+
+	// No second stack trace is captured
+	_, ok := err.(callstack.HasStackTrace)
+	assert.False(t, ok)
+}
+
+func TestKeyMissing(t *testing.T) {
+	_, ok := errors.Get(io.EOF, userID)
+	assert.False(t, ok)
+
+	// A value of a different type is not returned
+	_, ok = errors.Get(errors.Fields{"user.id": "42"}.Wrap(io.EOF, "message"), userID)
+	assert.False(t, ok)
+
+	assert.Nil(t, errors.Set(nil, userID, 42))
+
+	// The stack trace is captured by Set() for errors without one
+	m := errors.ToMap(errors.Set(io.EOF, domain, "example.com"))
+	assert.Equal(t, "errors_test.TestKeyMissing", m["excFuncName"])
+}
@@ -0,0 +1,103 @@
+package errors
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Kinds which make up the canonical taxonomy. Each is mapped to an HTTP status (see
+// StatusForKind()) and, by the errgrpc package, to a gRPC code, such that an error
+// classified once using WithKind() is reported consistently over either transport.
+const (
+	KindInvalidArgument  Kind = "invalid_argument"
+	KindUnauthenticated  Kind = "unauthenticated"
+	KindPermissionDenied Kind = "permission_denied"
+	KindNotFound         Kind = "not_found"
+	KindAlreadyExists    Kind = "already_exists"
+	KindRateLimited      Kind = "rate_limited"
+	KindCanceled         Kind = "canceled"
+	KindInternal         Kind = "internal"
+	KindUnimplemented    Kind = "unimplemented"
+	KindUnavailable      Kind = "unavailable"
+	KindTimeout          Kind = "timeout"
+)
+
+var (
+	kindStatusMu sync.RWMutex
+	kindStatus   = map[Kind]int{
+		KindInvalidArgument:  http.StatusBadRequest,
+		KindUnauthenticated:  http.StatusUnauthorized,
+		KindPermissionDenied: http.StatusForbidden,
+		KindNotFound:         http.StatusNotFound,
+		KindAlreadyExists:    http.StatusConflict,
+		KindRateLimited:      http.StatusTooManyRequests,
+		KindCanceled:         499, // Client Closed Request, as used by nginx
+		KindInternal:         http.StatusInternalServerError,
+		KindUnimplemented:    http.StatusNotImplemented,
+		KindUnavailable:      http.StatusServiceUnavailable,
+		KindTimeout:          http.StatusGatewayTimeout,
+	}
+	statusKind = reverseKinds(kindStatus)
+)
+
+// RegisterKindStatus maps kind to the HTTP status, replacing the canonical mapping if
+// kind is part of the taxonomy. The status is also mapped back to kind by KindForStatus(),
+// and the status kind was previously mapped to is no longer mapped back to it.
+// It should only be called once at startup, typically from init(). RegisterKindStatus
+// returns a function which restores the previous mapping.
+//
+//	errors.RegisterKindStatus("quota_exceeded", http.StatusPaymentRequired)
+func RegisterKindStatus(kind Kind, status int) (remove func()) {
+	kindStatusMu.Lock()
+	defer kindStatusMu.Unlock()
+	prevStatus, hadStatus := kindStatus[kind]
+	prevKind, hadKind := statusKind[status]
+	setKindStatus(kind, status)
+	return func() {
+		kindStatusMu.Lock()
+		defer kindStatusMu.Unlock()
+		if statusKind[status] == kind {
+			delete(statusKind, status)
+		}
+		delete(kindStatus, kind)
+		if hadStatus {
+			setKindStatus(kind, prevStatus)
+		}
+		if hadKind {
+			statusKind[status] = prevKind
+		}
+	}
+}
+
+// setKindStatus maps kind to status in both directions, the caller must hold kindStatusMu
+func setKindStatus(kind Kind, status int) {
+	if old, ok := kindStatus[kind]; ok && statusKind[old] == kind {
+		delete(statusKind, old)
+	}
+	kindStatus[kind] = status
+	statusKind[status] = kind
+}
+
+// StatusForKind returns the HTTP status kind is mapped to. It returns 0 if kind
+// is not mapped.
+func StatusForKind(kind Kind) int {
+	kindStatusMu.RLock()
+	defer kindStatusMu.RUnlock()
+	return kindStatus[kind]
+}
+
+// KindForStatus returns the Kind the HTTP status is mapped to. It returns "" if
+// status is not mapped.
+func KindForStatus(status int) Kind {
+	kindStatusMu.RLock()
+	defer kindStatusMu.RUnlock()
+	return statusKind[status]
+}
+
+func reverseKinds(m map[Kind]int) map[int]Kind {
+	result := make(map[int]Kind, len(m))
+	for kind, value := range m {
+		result[value] = kind
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package errors_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, errors.StatusForKind(errors.KindNotFound))
+	assert.Equal(t, errors.KindTimeout, errors.KindForStatus(http.StatusGatewayTimeout))
+	assert.Equal(t, 0, errors.StatusForKind("unknown_kind"))
+	assert.Equal(t, errors.Kind(""), errors.KindForStatus(http.StatusTeapot))
+
+	remove := errors.RegisterKindStatus("quota_exceeded", http.StatusPaymentRequired)
+	defer remove()
+	assert.Equal(t, http.StatusPaymentRequired, errors.StatusForKind("quota_exceeded"))
+	assert.Equal(t, errors.Kind("quota_exceeded"), errors.KindForStatus(http.StatusPaymentRequired))
+
+	// Remapping a kind removes the reverse mapping of its previous status
+	removeRemap := errors.RegisterKindStatus("quota_exceeded", http.StatusTooManyRequests)
+	assert.Equal(t, http.StatusTooManyRequests, errors.StatusForKind("quota_exceeded"))
+	assert.Equal(t, errors.Kind("quota_exceeded"), errors.KindForStatus(http.StatusTooManyRequests))
+	assert.Equal(t, errors.Kind(""), errors.KindForStatus(http.StatusPaymentRequired))
+
+	// Removing restores the previous mapping
+	removeRemap()
+	assert.Equal(t, http.StatusPaymentRequired, errors.StatusForKind("quota_exceeded"))
+	assert.Equal(t, errors.Kind("quota_exceeded"), errors.KindForStatus(http.StatusPaymentRequired))
+	assert.Equal(t, errors.KindRateLimited, errors.KindForStatus(http.StatusTooManyRequests))
+	assert.Equal(t, http.StatusTooManyRequests, errors.StatusForKind(errors.KindRateLimited))
+
+	remove()
+	assert.Equal(t, 0, errors.StatusForKind("quota_exceeded"))
+	assert.Equal(t, errors.Kind(""), errors.KindForStatus(http.StatusPaymentRequired))
+}
@@ -0,0 +1,132 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/trace"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log logs the error once using the provided logger, including all the fields and
+// stack trace information attached to the error. The message logged is the error
+// string. The level is chosen from the error using SeverityOf(); by default errors
+// with an HTTP status in the 4xx range (see WithStatus()) are considered user errors
+// and logged as warnings, all other errors are logged as errors.
+//
+// The logger should be either a *slog.Logger or a logrus.FieldLogger, use LogSlog()
+// or LogLogrus() to have the type checked at compile time. If logger is nil or of any
+// other type, slog.Default() is used, with the type of the logger attached under the
+// key `errLoggerType` such that the misconfiguration can be found. If err is nil,
+// nothing is logged.
+//
+//	if err := handle(req); err != nil {
+//		errors.Log(ctx, logger, err)
+//	}
+func Log(ctx context.Context, logger any, err error) {
+	if err == nil {
+		return
+	}
+	switch l := logger.(type) {
+	case nil:
+		LogSlog(ctx, slog.Default(), err)
+	case *slog.Logger:
+		LogSlog(ctx, l, err)
+	case logrus.FieldLogger:
+		LogLogrus(ctx, l, err)
+	default:
+		LogSlog(ctx, slog.Default().With("errLoggerType", fmt.Sprintf("%T", logger)), err)
+	}
+}
+
+// LogSlog is identical to Log() but for a *slog.Logger. If logger is nil,
+// slog.Default() is used.
+func LogSlog(ctx context.Context, logger *slog.Logger, err error) {
+	if err == nil {
+		return
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logSlog(ctx, logger, err, SeverityOf(err))
+}
+
+// LogLogrus is identical to Log() but for a logrus.FieldLogger. If logger is nil,
+// or a nil *logrus.Logger or *logrus.Entry, logrus.StandardLogger() is used.
+func LogLogrus(ctx context.Context, logger logrus.FieldLogger, err error) {
+	if err == nil {
+		return
+	}
+	switch l := logger.(type) {
+	case nil:
+		logger = logrus.StandardLogger()
+	case *logrus.Logger:
+		if l == nil {
+			logger = logrus.StandardLogger()
+		}
+	case *logrus.Entry:
+		if l == nil {
+			logger = logrus.StandardLogger()
+		}
+	}
+	LogrusEntry(logger, err, nil).WithContext(ctx).Log(logrusLevel(SeverityOf(err)), MessageOf(err))
+}
+
+func logSlog(ctx context.Context, logger *slog.Logger, err error, level slog.Level) {
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	m := ToMap(err)
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, m[key]))
+	}
+	logger.LogAttrs(ctx, level, MessageOf(err), attrs...)
+}
+
+// TraceCategory is the category of the events emitted by TraceLog()
+const TraceCategory = "error"
+
+// TraceLog emits err as a user log event in the execution trace, such that errors show
+// up inline with the goroutine and task which returned them when inspected using
+// `go tool trace`. The event message is the error string, prefixed with the code of
+// the error if it has one (see WithCode()). Nothing is emitted unless tracing was
+// started using runtime/trace.Start() or the /debug/pprof/trace endpoint.
+//
+//	if err := handle(ctx, req); err != nil {
+//		errors.TraceLog(ctx, err)
+//	}
+//
+// If err is nil, nothing is emitted.
+func TraceLog(ctx context.Context, err error) {
+	if err == nil || !trace.IsEnabled() {
+		return
+	}
+	msg := MessageOf(err)
+	if code := CodeOf(err); code != "" {
+		msg = code + ": " + msg
+	}
+	trace.Log(ctx, TraceCategory, msg)
+}
+
+// logrusLevel returns the logrus level equivalent to the slog level
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	}
+	return logrus.DebugLevel
+}
@@ -0,0 +1,111 @@
+package errors_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/trace"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	internal := errors.Fields{"key1": "value1"}.Wrap(io.EOF, "message")
+	user := errors.WrapOpts(io.EOF, "bad request", errors.WithStatus(http.StatusBadRequest))
+
+	t.Run("slog", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&b, nil))
+
+		errors.Log(ctx, logger, internal)
+		assert.Contains(t, b.String(), `level=ERROR msg="message: EOF"`)
+		assert.Contains(t, b.String(), "excFuncName=errors_test.TestLog")
+		assert.Contains(t, b.String(), "key1=value1")
+
+		b.Reset()
+		errors.Log(ctx, logger, user)
+		assert.Contains(t, b.String(), `level=WARN msg="bad request: EOF"`)
+
+		b.Reset()
+		errors.Log(ctx, logger, errors.WrapOpts(errors.New("x"), "m", errors.WithKind(errors.KindNotFound)))
+		assert.Contains(t, b.String(), `level=WARN msg="m: x"`)
+	})
+
+	t.Run("logrus", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&b)
+
+		errors.Log(ctx, logger, internal)
+		assert.Contains(t, b.String(), `level=error msg="message: EOF"`)
+		assert.Contains(t, b.String(), "key1=value1")
+
+		b.Reset()
+		errors.Log(ctx, logger.WithField("svc", "api"), user)
+		assert.Contains(t, b.String(), `level=warning msg="bad request: EOF"`)
+		assert.Contains(t, b.String(), "svc=api")
+	})
+
+	t.Run("nil error is not logged", func(t *testing.T) {
+		var b bytes.Buffer
+		errors.Log(ctx, slog.New(slog.NewTextHandler(&b, nil)), nil)
+		assert.Empty(t, b.String())
+	})
+
+	t.Run("unsupported logger uses slog.Default", func(t *testing.T) {
+		var b bytes.Buffer
+		defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+		slog.SetDefault(slog.New(slog.NewTextHandler(&b, nil)))
+
+		assert.NotPanics(t, func() { errors.Log(ctx, "logger", internal) })
+		assert.Contains(t, b.String(), `level=ERROR msg="message: EOF" errLoggerType=string`)
+		assert.Contains(t, b.String(), "key1=value1")
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		var b bytes.Buffer
+		errors.LogSlog(ctx, slog.New(slog.NewTextHandler(&b, nil)), user)
+		assert.Contains(t, b.String(), `level=WARN msg="bad request: EOF"`)
+
+		b.Reset()
+		logger := logrus.New()
+		logger.SetOutput(&b)
+		errors.LogLogrus(ctx, logger, internal)
+		assert.Contains(t, b.String(), `level=error msg="message: EOF"`)
+		assert.Contains(t, b.String(), "key1=value1")
+	})
+
+	t.Run("nil logrus logger uses logrus.StandardLogger", func(t *testing.T) {
+		var b bytes.Buffer
+		defer logrus.SetOutput(logrus.StandardLogger().Out)
+		logrus.SetOutput(&b)
+
+		var logger *logrus.Logger
+		assert.NotPanics(t, func() { errors.Log(ctx, logger, internal) })
+		assert.Contains(t, b.String(), `level=error msg="message: EOF"`)
+
+		b.Reset()
+		assert.NotPanics(t, func() { errors.LogLogrus(ctx, nil, internal) })
+		assert.Contains(t, b.String(), `level=error msg="message: EOF"`)
+	})
+}
+
+func TestTraceLog(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while fetching user", errors.WithCode("user.lookup"))
+
+	var buf bytes.Buffer
+	require.NoError(t, trace.Start(&buf))
+	errors.TraceLog(context.Background(), err)
+	errors.TraceLog(context.Background(), nil)
+	trace.Stop()
+
+	assert.Contains(t, buf.String(), errors.TraceCategory)
+	assert.Contains(t, buf.String(), "user.lookup: while fetching user: EOF")
+}
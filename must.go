@@ -0,0 +1,149 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// PanicError is the value Must() and its variants panic with. It records the
+// stack trace at the point Must was called.
+type PanicError struct {
+	Err   error
+	stack *callstack.CallStack
+}
+
+func (e *PanicError) Error() string {
+	return limitMsg(e.Err.Error())
+}
+
+func (e *PanicError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PanicError) ownFields() map[string]any { return nil }
+
+func (e *PanicError) message() string { return NoMsg }
+
+func (e *PanicError) clone() error {
+	c := *e
+	c.Err = Clone(e.Err)
+	return &c
+}
+
+func (e *PanicError) stripStack() error {
+	c := *e
+	c.stack = &callstack.CallStack{}
+	c.Err = StripStack(e.Err)
+	return &c
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (e *PanicError) Cause() error { return e.Err }
+
+func (e *PanicError) StackTrace() callstack.StackTrace {
+	return e.stack.StackTrace()
+}
+
+func (e *PanicError) writePlus(w io.Writer) bool {
+	writePlus(w, e.Err)
+	_, _ = fmt.Fprintf(w, "%+v", e.stack)
+	return true
+}
+
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			e.writePlus(s)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, MessageOf(e))
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", MessageOf(e))
+	}
+}
+
+// Must returns v if err is nil, otherwise it panics with a *PanicError which includes
+// the stack trace at the point Must was called. It is intended for initialization code
+// where an error is unrecoverable.
+//
+//	var tmpl = errors.Must(template.ParseFiles("index.html"))
+func Must[T any](v T, err error) T {
+	if err != nil {
+		panic(&PanicError{Err: err, stack: callstack.New(1)})
+	}
+	return v
+}
+
+// Must2 is identical to Must but for functions which return two values and an error
+func Must2[A, B any](a A, b B, err error) (A, B) {
+	if err != nil {
+		panic(&PanicError{Err: err, stack: callstack.New(1)})
+	}
+	return a, b
+}
+
+// Must3 is identical to Must but for functions which return three values and an error
+func Must3[A, B, C any](a A, b B, c C, err error) (A, B, C) {
+	if err != nil {
+		panic(&PanicError{Err: err, stack: callstack.New(1)})
+	}
+	return a, b, c
+}
+
+// checkPanic is the value Check() panics with, such that Catch() only
+// recovers panics raised by Check()
+type checkPanic struct {
+	err error
+}
+
+// Check panics with err wrapped with the message and a stack trace if err is non-nil.
+// The panic must be recovered by a deferred call to Catch() in the same or a calling
+// function. This is opt-in sugar for deeply nested code such as parsers, where
+// checking each error would obscure the logic.
+//
+//	func parseConfig(b []byte) (cfg Config, err error) {
+//		defer errors.Catch(&err)
+//		cfg.Name = parseName(b)
+//		...
+//	}
+//
+//	func parseName(b []byte) string {
+//		name, err := readString(b)
+//		errors.Check(err, "while parsing name")
+//		return name
+//	}
+func Check(err error, msg string) {
+	if err == nil {
+		return
+	}
+	panic(&checkPanic{err: observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		msg:     msg,
+	})})
+}
+
+// Catch recovers a panic raised by Check() and assigns the error to the error pointed
+// to by errp. It must be called directly by defer. Panics not raised by Check()
+// are re-panicked.
+func Catch(errp *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if c, ok := r.(*checkPanic); ok {
+		*errp = c.err
+		return
+	}
+	panic(r)
+}
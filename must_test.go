@@ -0,0 +1,77 @@
+package errors_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recoverPanic(f func()) (r any) {
+	defer func() { r = recover() }()
+	f()
+	return nil
+}
+
+func TestMust(t *testing.T) {
+	assert.Equal(t, 1, errors.Must(1, nil))
+	a, b := errors.Must2(1, "b", nil)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, "b", b)
+	a, b, c := errors.Must3(1, "b", 3.0, nil)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, "b", b)
+	assert.Equal(t, 3.0, c)
+
+	for _, f := range []func(){
+		func() { errors.Must(1, io.EOF) },
+		func() { errors.Must2(1, 2, io.EOF) },
+		func() { errors.Must3(1, 2, 3, io.EOF) },
+	} {
+		r := recoverPanic(f)
+		pe, ok := r.(*errors.PanicError)
+		require.True(t, ok)
+		assert.Equal(t, "EOF", pe.Error())
+		assert.True(t, errors.Is(pe, io.EOF))
+
+		caller := callstack.GetLastFrame(pe.StackTrace())
+		assert.Regexp(t, `^errors_test.TestMust.func\d$`, caller.Func)
+		assert.Contains(t, fmt.Sprintf("%+v", pe), "must_test.go")
+	}
+}
+
+func parseName(name string, err error) string {
+	errors.Check(err, "while parsing name")
+	return name
+}
+
+func parseConfig(err error) (name string, result error) {
+	defer errors.Catch(&result)
+	name = parseName("config", err)
+	return name, nil
+}
+
+func TestCheck(t *testing.T) {
+	name, err := parseConfig(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "config", name)
+
+	_, err = parseConfig(io.EOF)
+	require.Error(t, err)
+	assert.Equal(t, "while parsing name: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "errors_test.parseName", errors.ToMap(err)["excFuncName"])
+
+	t.Run("other panics are not recovered", func(t *testing.T) {
+		r := recoverPanic(func() {
+			var err error
+			defer errors.Catch(&err)
+			panic("other")
+		})
+		assert.Equal(t, "other", r)
+	})
+}
@@ -0,0 +1,297 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// HasCode is implemented by errors which carry an application specific error code.
+//
+// The wrappers returned by WrapOpts() implement HasCode, HasStatus, HasKind and HasID
+// even when the option was not provided, in which case they return the zero value. As
+// such a wrapper closer to the top of the chain shadows the value below it when using
+// As(), use CodeOf(), StatusOf(), KindOf() and IDOf() which skip the zero values.
+type HasCode interface {
+	Code() string
+}
+
+// HasStatus is implemented by errors which carry an HTTP status code
+type HasStatus interface {
+	Status() int
+}
+
+// Kind classifies an error, such as "not_found" or "timeout", independent
+// of the transport used to report it.
+type Kind string
+
+// HasKind is implemented by errors which carry a Kind
+type HasKind interface {
+	Kind() Kind
+}
+
+// HasID is implemented by errors which carry an identifier, which can be used
+// to correlate an error reported to a client with the error that was logged.
+type HasID interface {
+	ID() string
+}
+
+// Option modifies the error returned by WrapOpts()
+type Option func(*wrapOptions)
+
+type wrapOptions struct {
+	fields  Fields
+	code    string
+	status  int
+	kind    Kind
+	id      string
+	noStack bool
+	skip    int
+}
+
+// WithCode attaches an application specific error code
+func WithCode(code string) Option {
+	return func(o *wrapOptions) {
+		o.code = code
+	}
+}
+
+// CodeError returns an error which carries code and matches, using Is(), any error in a
+// chain which carries the same code. This allows code based matching to use the same
+// control flow as sentinel errors.
+//
+//	if errors.Is(err, errors.CodeError("user.not_found")) {
+//		...
+//	}
+//
+// Only errors from this package match by code, errors from other packages which
+// implement HasCode only match if they implement Is() themselves.
+func CodeError(code string) error {
+	return codeError{code: code}
+}
+
+type codeError struct {
+	code string
+}
+
+func (c codeError) Error() string {
+	return "code '" + c.code + "'"
+}
+
+func (c codeError) Code() string {
+	return c.code
+}
+
+// isCode returns true if target is a CodeError() which matches code
+func isCode(code string, target error) bool {
+	t, ok := target.(codeError)
+	return ok && code != "" && t.code == code
+}
+
+// WithStatus attaches an HTTP status code
+func WithStatus(status int) Option {
+	return func(o *wrapOptions) {
+		o.status = status
+	}
+}
+
+// WithKind attaches a Kind which classifies the error
+func WithKind(kind Kind) Option {
+	return func(o *wrapOptions) {
+		o.kind = kind
+	}
+}
+
+// WithID attaches an identifier to the error
+func WithID(id string) Option {
+	return func(o *wrapOptions) {
+		o.id = id
+	}
+}
+
+// WithFieldsOpt attaches fields, as if the error was wrapped using Fields.Wrap().
+// If provided more than once, the fields are merged.
+func WithFieldsOpt(f Fields) Option {
+	return func(o *wrapOptions) {
+		o.fields = o.fields.Merge(f)
+	}
+}
+
+// NoStack avoids capturing a stack trace
+func NoStack() Option {
+	return func(o *wrapOptions) {
+		o.noStack = true
+	}
+}
+
+// Skip skips 'n' additional frames when capturing the stack trace, such that
+// helper functions which call WrapOpts() can report the location of their caller.
+func Skip(n int) Option {
+	return func(o *wrapOptions) {
+		o.skip = n
+	}
+}
+
+// WrapOpts wraps the error with a message and applies all the provided options
+// using a single wrapper, instead of stacking a separate wrapper for each.
+//
+//	return errors.WrapOpts(err, "while fetching user",
+//		errors.WithCode("user.not_found"),
+//		errors.WithStatus(http.StatusNotFound),
+//		errors.WithFieldsOpt(errors.Fields{"user": id}))
+//
+// If err is nil, WrapOpts returns nil.
+func WrapOpts(err error, msg string, opts ...Option) error {
+	if err == nil {
+		return nil
+	}
+	var o wrapOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	a := annotated{
+		fields:  o.fields,
+		code:    o.code,
+		status:  o.status,
+		kind:    o.kind,
+		id:      o.id,
+		wrapped: err,
+		msg:     msg,
+	}
+	if o.noStack {
+		return &a
+	}
+	return observeWrap(&annotatedStack{
+		annotated: a,
+		Trace:     callstack.Capture(1 + o.skip),
+	})
+}
+
+type annotated struct {
+	fields  Fields
+	code    string
+	status  int
+	kind    Kind
+	id      string
+	msg     string
+	wrapped error
+}
+
+func (a *annotated) Unwrap() error {
+	return a.wrapped
+}
+
+func (a *annotated) ownFields() map[string]any { return a.fields }
+
+func (a *annotated) message() string { return a.msg }
+
+func (a *annotated) clone() error {
+	c := *a
+	c.fields = Fields(nil).Merge(a.fields)
+	c.wrapped = Clone(a.wrapped)
+	return &c
+}
+
+func (a *annotated) stripStack() error {
+	c := *a
+	c.wrapped = StripStack(a.wrapped)
+	return &c
+}
+
+func (a *annotated) Is(target error) bool {
+	if isCode(a.code, target) {
+		return true
+	}
+	_, ok := target.(*annotated)
+	return ok && MatchWrapperType
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (a *annotated) Cause() error { return a.wrapped }
+
+func (a *annotated) Error() string {
+	if a.msg == NoMsg {
+		return limitMsg(a.wrapped.Error())
+	}
+	return limitMsg(a.msg + ": " + a.wrapped.Error())
+}
+
+func (a *annotated) Code() string {
+	return a.code
+}
+
+func (a *annotated) Status() int {
+	return a.status
+}
+
+func (a *annotated) Kind() Kind {
+	return a.kind
+}
+
+func (a *annotated) ID() string {
+	return a.id
+}
+
+func (a *annotated) HasFields() map[string]any {
+	return mergeFields(a)
+}
+
+func (a *annotated) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		a.writePlus(s)
+		return
+	}
+	_, _ = io.WriteString(s, MessageOf(a))
+}
+
+func (a *annotated) writePlus(w io.Writer) bool {
+	if len(a.fields) == 0 {
+		_, _ = io.WriteString(w, MessageOf(a))
+		return false
+	}
+	f := fields{fields: setOf(a.fields)}
+	return formatPlus(w, a.msg, a.wrapped, " ("+f.FormatFields()+")")
+}
+
+// annotatedStack is an annotated error with a stack trace
+type annotatedStack struct {
+	annotated
+	callstack.Trace
+}
+
+func (a *annotatedStack) clone() error {
+	c := *a
+	c.fields = Fields(nil).Merge(a.fields)
+	c.wrapped = Clone(a.wrapped)
+	return &c
+}
+
+// stripStack drops the stack entirely by returning the annotations alone
+func (a *annotatedStack) stripStack() error {
+	return a.annotated.stripStack()
+}
+
+func (a *annotatedStack) Is(target error) bool {
+	if isCode(a.code, target) {
+		return true
+	}
+	_, ok := target.(*annotatedStack)
+	return ok && MatchWrapperType
+}
+
+func (a *annotatedStack) Format(s fmt.State, verb rune) {
+	a.annotated.Format(s, verb)
+}
+
+func (a *annotatedStack) StackTrace() callstack.StackTrace {
+	if child, ok := a.wrapped.(callstack.HasStackTrace); ok {
+		return child.StackTrace()
+	}
+	return a.Trace.StackTrace()
+}
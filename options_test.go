@@ -0,0 +1,91 @@
+package errors_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapOpts(t *testing.T) {
+	err := errors.WrapOpts(&ErrTest{Msg: "query error"}, "message",
+		errors.WithCode("user.not_found"),
+		errors.WithStatus(http.StatusNotFound),
+		errors.WithKind("not_found"),
+		errors.WithID("abc123"),
+		errors.WithFieldsOpt(errors.Fields{"key1": "value1"}),
+		errors.WithFieldsOpt(errors.Fields{"key2": "value2"}),
+	)
+
+	assert.Equal(t, "message: query error", err.Error())
+	assert.Equal(t, "message: query error", fmt.Sprintf("%v", err))
+	assert.Regexp(t, `^message: query error \(key\d=value\d, key\d=value\d\)$`, fmt.Sprintf("%+v", err))
+	assert.True(t, errors.Is(err, &ErrTest{}))
+	assert.Equal(t, &ErrTest{Msg: "query error"}, pkgErrorCause(err))
+
+	assert.Equal(t, "user.not_found", errors.CodeOf(err))
+	assert.Equal(t, http.StatusNotFound, errors.StatusOf(err))
+	assert.Equal(t, errors.Kind("not_found"), errors.KindOf(err))
+	assert.Equal(t, "abc123", errors.IDOf(err))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "value1", m["key1"])
+	assert.Equal(t, "value2", m["key2"])
+	assert.Equal(t, "errors_test.TestWrapOpts", m["excFuncName"])
+	assert.Equal(t, "*errors_test.ErrTest", m["excType"])
+}
+
+func TestWrapOptsNoStack(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "message", errors.NoStack())
+	assert.Equal(t, "message: EOF", err.Error())
+
+	var stack callstack.HasStackTrace
+	assert.False(t, errors.As(err, &stack))
+}
+
+func wrapHelper(err error) error {
+	return errors.WrapOpts(err, "helper", errors.Skip(1))
+}
+
+func TestWrapOptsSkip(t *testing.T) {
+	m := errors.ToMap(wrapHelper(io.EOF))
+	assert.Equal(t, "errors_test.TestWrapOptsSkip", m["excFuncName"])
+}
+
+func TestWrapOptsNil(t *testing.T) {
+	assert.Nil(t, errors.WrapOpts(nil, "message", errors.WithCode("code")))
+}
+
+func TestCodeError(t *testing.T) {
+	notFound := errors.CodeError("user.not_found")
+	err := errors.WrapOpts(io.EOF, "while fetching user", errors.WithCode("user.not_found"))
+	err = errors.Wrap(err, "while handling request")
+
+	assert.True(t, errors.Is(err, notFound))
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.False(t, errors.Is(err, errors.CodeError("user.disabled")))
+	assert.False(t, errors.Is(io.EOF, notFound))
+	assert.False(t, errors.Is(errors.WrapOpts(io.EOF, "no code", errors.NoStack()), errors.CodeError("")))
+
+	// The CodeError itself matches and carries the code
+	assert.True(t, errors.Is(errors.Wrap(notFound, "returned"), notFound))
+	assert.Equal(t, "user.not_found", errors.CodeOf(notFound))
+	assert.Equal(t, "code 'user.not_found'", notFound.Error())
+}
+
+func TestWrapOptsAccessorsShadowed(t *testing.T) {
+	err := errors.WrapOpts(io.EOF, "while fetching user", errors.WithCode("user.not_found"))
+	err = errors.WrapOpts(err, "while handling request", errors.WithStatus(http.StatusNotFound))
+
+	// As() stops at the nearest wrapper, which implements HasCode without a code
+	var code errors.HasCode
+	assert.True(t, errors.As(err, &code))
+	assert.Equal(t, "", code.Code())
+
+	assert.Equal(t, "user.not_found", errors.CodeOf(err))
+	assert.Equal(t, http.StatusNotFound, errors.StatusOf(err))
+}
@@ -0,0 +1,95 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Recorder keeps the most recent errors created by this package in a ring buffer, such
+// that live services can be inspected for recent failures without trawling logs. Each
+// failure is recorded once, when it is first wrapped by an error which captures a stack
+// trace; wrapping an error which already has a stack trace is not recorded again.
+//
+// Recorder implements http.Handler which responds with the recorded errors as JSON.
+//
+//	http.Handle("/debug/errors", errors.EnableRecorder(100))
+type Recorder struct {
+	mu      sync.Mutex
+	entries []recorded
+	next    int
+	full    bool
+}
+
+// RecordedError is an error kept by the Recorder
+type RecordedError struct {
+	Time  time.Time `json:"time"`
+	Error *Envelope `json:"error"`
+}
+
+type recorded struct {
+	time time.Time
+	err  error
+}
+
+var activeRecorder atomic.Pointer[Recorder]
+
+// EnableRecorder starts recording the last n errors created by this package and returns
+// the Recorder. Any previously enabled Recorder is replaced. If n is less than one, the
+// recorder is disabled and EnableRecorder returns nil.
+func EnableRecorder(n int) *Recorder {
+	if n < 1 {
+		activeRecorder.Store(nil)
+		return nil
+	}
+	r := &Recorder{entries: make([]recorded, n)}
+	activeRecorder.Store(r)
+	return r
+}
+
+// Record adds err to the recorder, for errors which were not created by this package
+func (r *Recorder) Record(err error) {
+	if err == nil {
+		return
+	}
+	r.record(err)
+}
+
+func (r *Recorder) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = recorded{time: NowFunc(), err: err}
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Errors returns the recorded errors, oldest first
+func (r *Recorder) Errors() []RecordedError {
+	r.mu.Lock()
+	entries := append([]recorded{}, r.entries[:r.next]...)
+	if r.full {
+		entries = append(append([]recorded{}, r.entries[r.next:]...), entries...)
+	}
+	r.mu.Unlock()
+
+	// The envelopes are built outside the lock, as resolving stack traces is expensive
+	result := make([]RecordedError, len(entries))
+	for i, e := range entries {
+		result[i] = RecordedError{Time: e.time, Error: ToEnvelope(e.err)}
+	}
+	return result
+}
+
+// ServeHTTP responds with the recorded errors as a JSON array, newest first
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	errs := r.Errors()
+	for i, j := 0, len(errs)-1; i < j; i, j = i+1, j-1 {
+		errs[i], errs[j] = errs[j], errs[i]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(errs)
+}
@@ -0,0 +1,53 @@
+package errors_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	r := errors.EnableRecorder(2)
+	defer errors.EnableRecorder(0)
+
+	err := errors.Wrap(io.EOF, "first")
+	_ = errors.Fields{"key1": "value1"}.Wrap(err, "wrapping again is not recorded")
+	_ = errors.Fields{"key2": "value2"}.Wrap(io.ErrClosedPipe, "second")
+	_ = errors.Errorf("third: %d", 3)
+
+	recorded := r.Errors()
+	require.Len(t, recorded, 2)
+	assert.Equal(t, "second: io: read/write on closed pipe", recorded[0].Error.Message)
+	assert.Equal(t, "value2", recorded[0].Error.Fields["key2"])
+	require.NotEmpty(t, recorded[0].Error.Frames)
+	assert.Equal(t, "errors_test.TestRecorder", recorded[0].Error.Frames[0].Func)
+	assert.Equal(t, "third: 3", recorded[1].Error.Message)
+
+	r.Record(io.ErrUnexpectedEOF)
+	r.Record(nil)
+	require.Len(t, r.Errors(), 2)
+	assert.Equal(t, "unexpected EOF", r.Errors()[1].Error.Message)
+
+	t.Run("ServeHTTP", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/errors", nil))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var resp []errors.RecordedError
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp, 2)
+		// Newest first
+		assert.Equal(t, "unexpected EOF", resp[0].Error.Message)
+		assert.Equal(t, "third: 3", resp[1].Error.Message)
+	})
+
+	assert.Nil(t, errors.EnableRecorder(0))
+	_ = errors.Wrap(io.EOF, "not recorded")
+	assert.Len(t, r.Errors(), 2)
+}
@@ -0,0 +1,83 @@
+package errors
+
+import (
+	"sync"
+)
+
+// Redactor inspects a field as it is exported and returns the value to export in its
+// place and true, or false if the value should be exported unchanged. This allows
+// values to be redacted based on their content rather than their key.
+//
+//	var email = regexp.MustCompile(`[^@\s]+@[^@\s]+`)
+//
+//	errors.RegisterRedactor(func(key string, value any) (any, bool) {
+//		if s, ok := value.(string); ok && email.MatchString(s) {
+//			return email.ReplaceAllString(s, errors.Redacted), true
+//		}
+//		return nil, false
+//	})
+type Redactor func(key string, value any) (any, bool)
+
+var (
+	redactorsMu sync.RWMutex
+	redactors   []*Redactor
+)
+
+// RegisterRedactor adds a redactor applied to the fields exported by ToMap(), ToLogrus(),
+// FieldsOf() and ToJSON(). Redactors are run in the order they were registered, the first
+// one which returns true decides the value. It is safe to call from init() in multiple
+// packages. RegisterRedactor returns a function which removes the redactor.
+//
+//	remove := errors.RegisterRedactor(errors.HashRedactor(salt, "token"))
+//	defer remove()
+func RegisterRedactor(r Redactor) (remove func()) {
+	entry := &r
+	redactorsMu.Lock()
+	defer redactorsMu.Unlock()
+	redactors = append(redactors, entry)
+	return func() {
+		redactorsMu.Lock()
+		defer redactorsMu.Unlock()
+		redactors = without(redactors, entry)
+	}
+}
+
+// redactFields applies the registered redactors to each of the fields. The map is
+// never modified, if any value is redacted a copy is returned.
+func redactFields(f map[string]any) map[string]any {
+	redactorsMu.RLock()
+	defer redactorsMu.RUnlock()
+	if len(redactors) == 0 {
+		return f
+	}
+	result, copied := f, false
+	for key, value := range f {
+		redacted, ok := redactValue(key, value)
+		if !ok {
+			continue
+		}
+		if !copied {
+			result, copied = make(map[string]any, len(f)), true
+			for k, v := range f {
+				result[k] = v
+			}
+		}
+		result[key] = redacted
+	}
+	return result
+}
+
+// redactValue returns the value decided by the first redactor which returns true for
+// the field, or false if none does. Values which were already redacted, such as the
+// fields of an exported error, are left as is. The caller must hold redactorsMu.
+func redactValue(key string, value any) (any, bool) {
+	if IsRedacted(value) {
+		return nil, false
+	}
+	for _, r := range redactors {
+		if redacted, ok := (*r)(key, value); ok {
+			return redacted, true
+		}
+	}
+	return nil, false
+}
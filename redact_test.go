@@ -0,0 +1,47 @@
+package errors_test
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var email = regexp.MustCompile(`[^@\s]+@[^@\s]+`)
+
+func TestRegisterRedactor(t *testing.T) {
+	remove := errors.RegisterRedactor(func(key string, value any) (any, bool) {
+		if s, ok := value.(string); ok && email.MatchString(s) {
+			return email.ReplaceAllString(s, errors.Redacted), true
+		}
+		return nil, false
+	})
+	defer remove()
+
+	err := errors.Fields{"recipient": "to: user@example.com", "domain": "example.com"}.Wrap(io.EOF, "while sending")
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "to: [REDACTED]", m["recipient"])
+	assert.Equal(t, "example.com", m["domain"])
+	assert.Equal(t, "to: [REDACTED]", errors.ToLogrus(err)["recipient"])
+	assert.Equal(t, "to: [REDACTED]", errors.FieldsOf(err)["recipient"])
+
+	b, jsonErr := errors.ToJSON(err)
+	require.NoError(t, jsonErr)
+	var env errors.Envelope
+	require.NoError(t, json.Unmarshal(b, &env))
+	assert.Equal(t, "to: [REDACTED]", env.Fields["recipient"])
+
+	// The fields of the error are never modified
+	var f errors.HasFields
+	require.True(t, errors.As(err, &f))
+	assert.Equal(t, "to: user@example.com", f.HasFields()["recipient"])
+
+	// Once removed, the redactor no longer applies
+	remove()
+	assert.Equal(t, "to: user@example.com", errors.ToMap(err)["recipient"])
+}
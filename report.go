@@ -0,0 +1,110 @@
+package errors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// Fingerprint returns a short identifier for the location and type of err which is
+// the same for all errors created at the same place, regardless of the values in
+// their messages. It is derived from the type of the root cause and the frame where
+// the error occurred, or the root cause message if err has no stack trace.
+// If err is nil, Fingerprint returns "".
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	root := err
+	for next := Unwrap(root); next != nil; next = Unwrap(root) {
+		root = next
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintln(h, typeName(root))
+	if caller, ok := Caller(err); ok {
+		_, _ = fmt.Fprintf(h, "%s\n%s:%d", caller.Func, caller.File, caller.LineNo)
+	} else {
+		_, _ = fmt.Fprint(h, root.Error())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Report summarizes a collection of errors, see Summarize()
+type Report struct {
+	Total  int           `json:"total"`
+	Groups []ReportGroup `json:"groups"`
+}
+
+// ReportGroup is a group of errors which have the same code, or the same
+// Fingerprint() if they have no code.
+type ReportGroup struct {
+	Key   string `json:"key"`
+	Code  string `json:"code,omitempty"`
+	Count int    `json:"count"`
+	// Message is the message of the first error in the group
+	Message string `json:"message"`
+	// Frames is the stack trace of the first error in the group
+	Frames []callstack.FrameRecord `json:"frames,omitempty"`
+	// Example is the first error in the group
+	Example error `json:"-"`
+}
+
+// Summarize groups errs by code, or by Fingerprint() for errors without a code, and
+// counts the occurrences in each group, keeping the first error of each group as an
+// example. Groups are ordered by count, largest first. Nil errors are ignored.
+//
+//	report := errors.Summarize(itemErrs)
+//	fmt.Print(report)
+func Summarize(errs []error) Report {
+	var r Report
+	index := map[string]int{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		r.Total++
+		code := CodeOf(err)
+		key := code
+		if key == "" {
+			key = Fingerprint(err)
+		}
+		if i, ok := index[key]; ok {
+			r.Groups[i].Count++
+			continue
+		}
+		g := ReportGroup{Key: key, Code: code, Count: 1, Message: MessageOf(err), Example: err}
+		if trace, ok := StackOf(err); ok {
+			g.Frames = trace.Records()
+		}
+		index[key] = len(r.Groups)
+		r.Groups = append(r.Groups, g)
+	}
+	sort.SliceStable(r.Groups, func(i, j int) bool {
+		return r.Groups[i].Count > r.Groups[j].Count
+	})
+	return r
+}
+
+// String returns a printable summary of the report
+func (r Report) String() string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "%d errors in %d groups\n", r.Total, len(r.Groups))
+	for _, g := range r.Groups {
+		_, _ = fmt.Fprintf(&b, "%6dx ", g.Count)
+		if g.Code != "" {
+			_, _ = fmt.Fprintf(&b, "[%s] ", g.Code)
+		}
+		b.WriteString(g.Message)
+		b.WriteString("\n")
+		if len(g.Frames) != 0 {
+			f := g.Frames[0]
+			_, _ = fmt.Fprintf(&b, "        at %s (%s:%d)\n", f.Func, f.File, f.Line)
+		}
+	}
+	return b.String()
+}
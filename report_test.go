@@ -0,0 +1,59 @@
+package errors_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failItem(id int) error {
+	return errors.Wrapf(io.EOF, "while fetching item %d", id)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, errors.Fingerprint(failItem(1)), errors.Fingerprint(failItem(2)))
+	assert.NotEqual(t, errors.Fingerprint(failItem(1)), errors.Fingerprint(errors.Wrap(io.EOF, "other")))
+	assert.Len(t, errors.Fingerprint(io.EOF), 16)
+	assert.NotEqual(t, errors.Fingerprint(io.EOF), errors.Fingerprint(io.ErrClosedPipe))
+	assert.Equal(t, "", errors.Fingerprint(nil))
+}
+
+func TestSummarize(t *testing.T) {
+	var errs []error
+	for i := 0; i < 3; i++ {
+		errs = append(errs, failItem(i))
+	}
+	errs = append(errs,
+		errors.WrapOpts(io.EOF, "item 10", errors.WithCode("item.missing")),
+		nil,
+		errors.WrapOpts(io.ErrClosedPipe, "item 11", errors.WithCode("item.missing")),
+		io.ErrUnexpectedEOF,
+	)
+
+	r := errors.Summarize(errs)
+	assert.Equal(t, 6, r.Total)
+	require.Len(t, r.Groups, 3)
+
+	assert.Equal(t, 3, r.Groups[0].Count)
+	assert.Equal(t, "while fetching item 0: EOF", r.Groups[0].Message)
+	assert.Equal(t, errs[0], r.Groups[0].Example)
+	require.NotEmpty(t, r.Groups[0].Frames)
+	assert.Equal(t, "errors_test.failItem", r.Groups[0].Frames[0].Func)
+
+	assert.Equal(t, 2, r.Groups[1].Count)
+	assert.Equal(t, "item.missing", r.Groups[1].Key)
+	assert.Equal(t, "item.missing", r.Groups[1].Code)
+
+	assert.Equal(t, 1, r.Groups[2].Count)
+	assert.Empty(t, r.Groups[2].Frames)
+
+	out := r.String()
+	assert.Contains(t, out, "6 errors in 3 groups\n")
+	assert.Contains(t, out, "     3x while fetching item 0: EOF\n        at errors_test.failItem (")
+	assert.Contains(t, out, "     2x [item.missing] item 10: EOF\n")
+	assert.Contains(t, fmt.Sprint(r), "     1x unexpected EOF\n")
+}
@@ -0,0 +1,263 @@
+package errors
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sink receives the errors forwarded by a Reporter. Suppressed is the number of
+// duplicates of err which were not forwarded since the last time it was forwarded.
+// Duplicates still pending when the window expires, or when the Reporter is flushed,
+// are forwarded as the last duplicate, with suppressed counting the others.
+type Sink func(err error, suppressed int)
+
+// LogSink returns a Sink which logs errors using Log()
+func LogSink(logger *slog.Logger) Sink {
+	return func(err error, suppressed int) {
+		if suppressed > 0 {
+			err = AddFields(err, Fields{"suppressed": suppressed})
+		}
+		Log(context.Background(), logger, err)
+	}
+}
+
+// ChanSink returns a Sink which sends errors to ch. Errors are dropped
+// if ch is not ready to receive, such that the Reporter never blocks.
+func ChanSink(ch chan<- error) Sink {
+	return func(err error, _ int) {
+		select {
+		case ch <- err:
+		default:
+		}
+	}
+}
+
+// ReporterConfig tunes the deduplication and rate limiting of a Reporter
+type ReporterConfig struct {
+	// Window is the time during which duplicates of an error, as determined by
+	// Fingerprint(), are suppressed after it is forwarded. Defaults to one minute.
+	Window time.Duration
+	// Limit is the maximum number of errors forwarded during each Interval,
+	// errors over the limit are dropped. Zero means no limit.
+	Limit int
+	// Interval is the period over which Limit applies. Defaults to one second.
+	Interval time.Duration
+}
+
+// Reporter forwards errors to a Sink, suppressing duplicates and limiting the rate of
+// errors forwarded, such that error storms do not flood downstream systems. Call Flush()
+// before shutting down, such that the duplicates suppressed so far are not lost.
+//
+//	r := errors.NewReporter(errors.LogSink(slog.Default()), errors.ReporterConfig{Limit: 10})
+//	errors.OnWrap(r.Report)
+//	defer r.Flush()
+type Reporter struct {
+	sink Sink
+	conf ReporterConfig
+
+	mu          sync.Mutex
+	seen        map[string]*reported
+	windowStart time.Time
+	sent        int
+	dropped     int
+	thresholds  []*thresholdState
+}
+
+// Threshold is a rule which invokes Alert when more than Count errors matching
+// Code and Kind are reported within Window. An empty Code or Kind matches any error.
+// Window must be positive.
+//
+//	r.AddThreshold(errors.Threshold{
+//		Code:   "db.timeout",
+//		Count:  100,
+//		Window: time.Minute,
+//		Alert:  func(last error) { pager.Trigger("db timeouts", last) },
+//	})
+type Threshold struct {
+	Code   string
+	Kind   Kind
+	Count  int
+	Window time.Duration
+	// Alert is called with the error which exceeded the threshold. It is called
+	// once each time the threshold is exceeded, after which counting starts over.
+	Alert func(last error)
+}
+
+type thresholdState struct {
+	Threshold
+	times []time.Time
+}
+
+type reported struct {
+	last       time.Time
+	suppressed int
+	// pending is the last of the suppressed duplicates
+	pending error
+}
+
+// pendingReport is a suppressed duplicate to be forwarded to the sink
+type pendingReport struct {
+	err        error
+	suppressed int
+}
+
+// NewReporter returns a Reporter which forwards errors to sink
+func NewReporter(sink Sink, conf ReporterConfig) *Reporter {
+	if conf.Window == 0 {
+		conf.Window = time.Minute
+	}
+	if conf.Interval == 0 {
+		conf.Interval = time.Second
+	}
+	return &Reporter{
+		sink: sink,
+		conf: conf,
+		seen: map[string]*reported{},
+	}
+}
+
+// Report forwards err to the sink unless it is a duplicate of an error forwarded
+// during the window, or the rate limit has been reached. If err is nil, Report does nothing.
+func (r *Reporter) Report(err error) {
+	if err == nil {
+		return
+	}
+	forward, suppressed, pending, alerts := r.record(err)
+
+	// The sink and alerts are called without the lock, as they might create errors of their own
+	r.forward(pending)
+	if forward {
+		r.sink(err, suppressed)
+	}
+	for _, alert := range alerts {
+		alert(err)
+	}
+}
+
+// record updates the state of the reporter with err, and returns whether err should be
+// forwarded, the suppressed duplicates to forward and the alerts of exceeded thresholds.
+func (r *Reporter) record(err error) (forward bool, suppressed int, pending []pendingReport, alerts []func(error)) {
+	key := Fingerprint(err)
+	now := NowFunc()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Thresholds count every error, including those which are suppressed or dropped
+	alerts = r.countThresholds(err, now)
+
+	if now.Sub(r.windowStart) >= r.conf.Interval {
+		r.windowStart = now
+		r.sent = 0
+		pending = r.expire(now, key)
+	}
+	entry, ok := r.seen[key]
+	if ok && now.Sub(entry.last) < r.conf.Window {
+		entry.suppressed++
+		entry.pending = err
+		return false, 0, pending, alerts
+	}
+	if r.conf.Limit > 0 && r.sent >= r.conf.Limit {
+		r.dropped++
+		return false, 0, pending, alerts
+	}
+	if ok {
+		suppressed = entry.suppressed
+	}
+	r.seen[key] = &reported{last: now}
+	r.sent++
+	return true, suppressed, pending, alerts
+}
+
+// Flush forwards the duplicates which were suppressed and not yet forwarded
+func (r *Reporter) Flush() {
+	r.forward(r.pending())
+}
+
+// pending returns the suppressed duplicates of every error and resets their counts
+func (r *Reporter) pending() []pendingReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pending []pendingReport
+	for _, entry := range r.seen {
+		if entry.suppressed > 0 {
+			pending = append(pending, entry.flush())
+		}
+	}
+	return pending
+}
+
+func (r *Reporter) forward(pending []pendingReport) {
+	for _, p := range pending {
+		r.sink(p.err, p.suppressed)
+	}
+}
+
+// AddThreshold adds a rule which alerts when too many matching errors are reported.
+// It panics if the Window of t is not positive.
+func (r *Reporter) AddThreshold(t Threshold) {
+	if t.Window <= 0 {
+		panic("errors: threshold window must be positive")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.thresholds = append(r.thresholds, &thresholdState{Threshold: t})
+}
+
+// countThresholds records err against the matching thresholds, and returns
+// the alerts for thresholds which were exceeded.
+func (r *Reporter) countThresholds(err error, now time.Time) []func(error) {
+	if len(r.thresholds) == 0 {
+		return nil
+	}
+	code, kind := CodeOf(err), KindOf(err)
+	var alerts []func(error)
+	for _, t := range r.thresholds {
+		if (t.Code != "" && t.Code != code) || (t.Kind != "" && t.Kind != kind) {
+			continue
+		}
+		// Discard the times which are no longer within the window
+		var i int
+		for i < len(t.times) && now.Sub(t.times[i]) >= t.Window {
+			i++
+		}
+		t.times = append(t.times[i:], now)
+		if len(t.times) > t.Count {
+			t.times = t.times[:0]
+			alerts = append(alerts, t.Alert)
+		}
+	}
+	return alerts
+}
+
+// Dropped returns the number of errors dropped because the rate limit was reached
+func (r *Reporter) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// expire removes errors which are no longer within the window, and returns the
+// suppressed duplicates of those errors. The error being reported under key is
+// skipped, as its suppressed duplicates are forwarded along with it.
+func (r *Reporter) expire(now time.Time, key string) []pendingReport {
+	var pending []pendingReport
+	for k, entry := range r.seen {
+		if k == key || now.Sub(entry.last) < r.conf.Window {
+			continue
+		}
+		if entry.suppressed > 0 {
+			pending = append(pending, entry.flush())
+		}
+		delete(r.seen, k)
+	}
+	return pending
+}
+
+// flush returns the pending duplicate of the entry and resets its count
+func (e *reported) flush() pendingReport {
+	p := pendingReport{err: e.pending, suppressed: e.suppressed - 1}
+	e.suppressed, e.pending = 0, nil
+	return p
+}
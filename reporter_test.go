@@ -0,0 +1,153 @@
+package errors_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sinkCall struct {
+	err        error
+	suppressed int
+}
+
+func failFetch() error {
+	return errors.Wrap(io.EOF, "while fetching")
+}
+
+func TestReporter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	errors.NowFunc = func() time.Time { return now }
+	defer func() { errors.NowFunc = time.Now }()
+
+	var calls []sinkCall
+	r := errors.NewReporter(func(err error, suppressed int) {
+		calls = append(calls, sinkCall{err: err, suppressed: suppressed})
+	}, errors.ReporterConfig{Window: time.Minute, Limit: 2, Interval: time.Second})
+
+	// Duplicates within the window are suppressed
+	for i := 0; i < 5; i++ {
+		r.Report(failFetch())
+	}
+	r.Report(nil)
+	require.Len(t, calls, 1)
+	assert.Equal(t, 0, calls[0].suppressed)
+
+	// Distinct errors are forwarded up to the limit
+	r.Report(errors.Wrap(io.ErrClosedPipe, "while writing"))
+	r.Report(io.ErrUnexpectedEOF)
+	require.Len(t, calls, 2)
+	assert.Equal(t, 1, r.Dropped())
+
+	// After the window, the duplicate count is reported
+	now = now.Add(time.Minute)
+	r.Report(failFetch())
+	require.Len(t, calls, 3)
+	assert.Equal(t, 4, calls[2].suppressed)
+}
+
+func TestReporterOnWrap(t *testing.T) {
+	ch := make(chan error, 1)
+	r := errors.NewReporter(errors.ChanSink(ch), errors.ReporterConfig{})
+	remove := errors.OnWrap(r.Report)
+
+	err := errors.Wrap(io.EOF, "while reading")
+	_ = errors.Wrap(err, "already reported")
+	_ = errors.Wrap(io.ErrClosedPipe, "channel is full and this is dropped")
+	remove()
+	_ = errors.Wrap(io.ErrUnexpectedEOF, "hook was removed")
+
+	require.Len(t, ch, 1)
+	assert.Equal(t, err, <-ch)
+}
+
+func TestLogSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	errors.LogSink(logger)(errors.Wrap(io.EOF, "while reading"), 3)
+	assert.Contains(t, buf.String(), "while reading: EOF")
+	assert.Contains(t, buf.String(), "suppressed=3")
+}
+
+func TestReporterThreshold(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	errors.NowFunc = func() time.Time { return now }
+	defer func() { errors.NowFunc = time.Now }()
+
+	var alerts []error
+	r := errors.NewReporter(func(error, int) {}, errors.ReporterConfig{})
+	r.AddThreshold(errors.Threshold{
+		Code:   "db.timeout",
+		Count:  3,
+		Window: time.Minute,
+		Alert:  func(last error) { alerts = append(alerts, last) },
+	})
+
+	timeout := func() error {
+		return errors.WrapOpts(io.EOF, "while querying", errors.WithCode("db.timeout"))
+	}
+
+	// Errors outside the window are not counted
+	r.Report(timeout())
+	now = now.Add(time.Minute)
+	r.Report(timeout())
+	r.Report(timeout())
+	r.Report(errors.WrapOpts(io.EOF, "other code", errors.WithCode("db.conflict")))
+	r.Report(timeout())
+	assert.Empty(t, alerts)
+
+	// Duplicates are counted even though they are suppressed
+	last := timeout()
+	r.Report(last)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, last, alerts[0])
+
+	// Counting starts over after an alert
+	r.Report(timeout())
+	assert.Len(t, alerts, 1)
+}
+
+func TestReporterFlush(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	errors.NowFunc = func() time.Time { return now }
+	defer func() { errors.NowFunc = time.Now }()
+
+	var calls []sinkCall
+	r := errors.NewReporter(func(err error, suppressed int) {
+		calls = append(calls, sinkCall{err: err, suppressed: suppressed})
+	}, errors.ReporterConfig{Window: time.Minute})
+
+	// Duplicates pending when the window expires are forwarded by the next report
+	r.Report(failFetch())
+	r.Report(failFetch())
+	last := failFetch()
+	r.Report(last)
+	now = now.Add(time.Minute)
+	r.Report(io.ErrUnexpectedEOF)
+	require.Len(t, calls, 3)
+	assert.Equal(t, last, calls[1].err)
+	assert.Equal(t, 1, calls[1].suppressed)
+	assert.Equal(t, io.ErrUnexpectedEOF, calls[2].err)
+
+	// Flush forwards the duplicates pending within the window
+	r.Report(io.ErrUnexpectedEOF)
+	r.Flush()
+	require.Len(t, calls, 4)
+	assert.Equal(t, io.ErrUnexpectedEOF, calls[3].err)
+	assert.Equal(t, 0, calls[3].suppressed)
+	r.Flush()
+	assert.Len(t, calls, 4)
+}
+
+func TestReporterThresholdWindow(t *testing.T) {
+	r := errors.NewReporter(func(error, int) {}, errors.ReporterConfig{})
+	assert.Panics(t, func() {
+		r.AddThreshold(errors.Threshold{Count: 3, Alert: func(error) {}})
+	})
+}
@@ -0,0 +1,88 @@
+package errors
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// RequestIDField is the field key the request id is attached under, such that
+// error logs can be joined with access logs using the same id.
+const RequestIDField = "request.id"
+
+// RequestIDContextKeys are the context keys WrapCtx() looks up the request id with,
+// the first key with a non-empty string value wins. It is empty by default, as such
+// no request id is found until the key used by the middleware is added. For instance,
+// the request id set by the chi RequestID middleware is only picked up after
+//
+//	errors.RequestIDContextKeys = append(errors.RequestIDContextKeys, middleware.RequestIDKey)
+//
+// The request id of gRPC calls is found by registering errgrpc.RequestIDExtractor
+// using RegisterContextExtractor(). It should only be modified once at startup.
+var RequestIDContextKeys []any
+
+// WithRequestID returns a new error wrapping err with the request id attached under
+// RequestIDField. No message or stack trace is added. If id is empty, err is
+// returned unchanged.
+//
+// If err is nil, WithRequestID returns nil.
+func WithRequestID(err error, id string) error {
+	if err == nil || id == "" {
+		return err
+	}
+	return WrapOpts(err, NoMsg, WithFieldsOpt(Fields{RequestIDField: id}), NoStack())
+}
+
+// requestIDFromContext returns the request id found using RequestIDContextKeys
+func requestIDFromContext(ctx context.Context) (string, bool) {
+	for _, key := range RequestIDContextKeys {
+		if id, ok := ctx.Value(key).(string); ok && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// RequestHeaders is the allowlist of headers WithRequest() attaches to errors. Headers
+// which are not on this list are never attached, as they might contain credentials.
+// It should only be modified once at startup.
+var RequestHeaders = []string{"User-Agent", "X-Request-Id", "X-Forwarded-For"}
+
+// WithRequest returns a new error wrapping err with fields describing the request;
+// the method, path, remote address and the headers found in RequestHeaders. The
+// X-Request-Id header is also attached under RequestIDField. Neither the request nor
+// its body is retained by the error.
+//
+//	if err != nil {
+//		return errors.WithRequest(err, r)
+//	}
+//
+// If err is nil, WithRequest returns nil.
+func WithRequest(err error, r *http.Request) error {
+	if err == nil {
+		return nil
+	}
+	f := Fields{
+		"http.method":      r.Method,
+		"http.remote_addr": r.RemoteAddr,
+	}
+	if r.URL != nil {
+		f["http.path"] = r.URL.Path
+	}
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		f[RequestIDField] = id
+	}
+	for _, name := range RequestHeaders {
+		if value := r.Header.Get(name); value != "" {
+			f["http.header."+strings.ToLower(name)] = value
+		}
+	}
+	return observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		fields:  setOf(f),
+	})
+}
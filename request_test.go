@@ -0,0 +1,61 @@
+package errors_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v3/domains?secret=1", strings.NewReader("body"))
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("X-Request-Id", "abc123")
+	r.Header.Set("Authorization", "Basic secret")
+
+	err := errors.WithRequest(io.EOF, r)
+	assert.Equal(t, "EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "POST", m["http.method"])
+	assert.Equal(t, "/v3/domains", m["http.path"])
+	assert.Equal(t, "192.0.2.1:1234", m["http.remote_addr"])
+	assert.Equal(t, "test-agent", m["http.header.user-agent"])
+	assert.Equal(t, "abc123", m["http.header.x-request-id"])
+	assert.Equal(t, "abc123", m[errors.RequestIDField])
+	assert.NotContains(t, m, "http.header.authorization")
+	assert.NotContains(t, m, "http.header.x-forwarded-for")
+	assert.Equal(t, "errors_test.TestWithRequest", m["excFuncName"])
+
+	assert.Nil(t, errors.WithRequest(nil, r))
+}
+
+type requestIDKey struct{}
+
+func TestWithRequestID(t *testing.T) {
+	err := errors.WithRequestID(io.EOF, "abc123")
+	assert.Equal(t, "EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "abc123", errors.ToMap(err)[errors.RequestIDField])
+
+	assert.Equal(t, io.EOF, errors.WithRequestID(io.EOF, ""))
+	assert.Nil(t, errors.WithRequestID(nil, "abc123"))
+}
+
+func TestRequestIDContextKeys(t *testing.T) {
+	defer func(keys []any) { errors.RequestIDContextKeys = keys }(errors.RequestIDContextKeys)
+	errors.RequestIDContextKeys = []any{requestIDKey{}}
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc123")
+	m := errors.ToMap(errors.WrapCtx(ctx, io.EOF, "while handling"))
+	assert.Equal(t, "abc123", m[errors.RequestIDField])
+
+	m = errors.ToMap(errors.WrapCtx(context.Background(), io.EOF, "while handling"))
+	assert.NotContains(t, m, errors.RequestIDField)
+}
@@ -0,0 +1,51 @@
+package errors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// SiteIDField is the field key the wrap site id is attached under, see WithSiteID()
+const SiteIDField = "wrap.site"
+
+// siteIDs caches the site id of each program counter, such that the file
+// and line of a call site are resolved and hashed only once.
+var siteIDs sync.Map
+
+// WithSiteID attaches a short stable identifier of the location WithSiteID was called
+// from under SiteIDField, derived from the file and line of the call. Unlike the stack
+// trace, the id is reported even when stack capture is disabled, such that dashboards
+// can group errors by the place they were wrapped in production.
+//
+//	return errors.WrapOpts(err, "while fetching user", errors.WithSiteID(), errors.NoStack())
+//
+// The location is resolved once per call site, subsequent calls only cost a lookup.
+// The id changes when the call moves to another line.
+func WithSiteID() Option {
+	id := siteID(1)
+	return func(o *wrapOptions) {
+		o.fields = o.fields.Merge(Fields{SiteIDField: id})
+	}
+}
+
+// siteID returns the site id of the caller, skip is the number of frames to skip
+// above the caller of siteID.
+func siteID(skip int) string {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return ""
+	}
+	if id, ok := siteIDs.Load(pcs[0]); ok {
+		return id.(string)
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	h := sha256.Sum256([]byte(callstack.NormalizePath(frame.File) + ":" + strconv.Itoa(frame.Line)))
+	id := hex.EncodeToString(h[:6])
+	siteIDs.Store(pcs[0], id)
+	return id
+}
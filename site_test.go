@@ -0,0 +1,36 @@
+package errors_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
+	"github.com/mailgun/errors/errtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func wrapAtSite(err error) error {
+	return errors.WrapOpts(err, "while fetching user", errors.WithSiteID())
+}
+
+func TestWithSiteID(t *testing.T) {
+	first := errors.FieldsOf(wrapAtSite(io.EOF))[errors.SiteIDField]
+	require.NotEmpty(t, first)
+	assert.Len(t, first, 12)
+
+	// The same site reports the same id, another site a different one
+	assert.Equal(t, first, errors.FieldsOf(wrapAtSite(io.ErrUnexpectedEOF))[errors.SiteIDField])
+	other := errors.WrapOpts(io.EOF, "while fetching user", errors.WithSiteID())
+	assert.NotEqual(t, first, errors.FieldsOf(other)[errors.SiteIDField])
+}
+
+func TestWithSiteIDNoStacks(t *testing.T) {
+	defer func(capture bool) { callstack.CaptureStacks = capture }(callstack.CaptureStacks)
+	callstack.CaptureStacks = false
+
+	err := wrapAtSite(io.EOF)
+	errtest.AssertNoStack(t, err)
+	assert.NotEmpty(t, errors.FieldsOf(err)[errors.SiteIDField])
+}
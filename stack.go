@@ -1,9 +1,9 @@
 package errors
 
 import (
-	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/mailgun/errors/callstack"
 )
@@ -14,22 +14,124 @@ func Stack(err error) error {
 	if err == nil {
 		return nil
 	}
-	return &stack{
-		err,
-		callstack.New(1),
+	return observeWrap(&stack{
+		error: err,
+		Trace: callstack.Capture(1),
+	})
+}
+
+// EnsureStackAt returns err unchanged if its chain already has a stack trace, otherwise
+// it annotates err with a stack trace captured 'skip' frames above the caller, where 0
+// is the caller of EnsureStackAt. It is intended for top level middleware which must
+// guarantee every logged error has a stack trace without capturing a second one.
+// If err is nil, EnsureStackAt returns nil.
+func EnsureStackAt(err error, skip int) error {
+	if err == nil {
+		return nil
+	}
+	var s callstack.HasStackTrace
+	if As(err, &s) {
+		return err
 	}
+	return observeWrap(&stack{
+		error: err,
+		Trace: callstack.Capture(1 + skip),
+	})
+}
+
+// WithStackOnce is identical to Stack() but returns err unchanged if its chain already
+// has a stack trace. As the stack trace closest to the cause is always preferred, a
+// second capture is wasted work on hot paths which pass along errors which usually
+// already have one.
+// If err is nil, WithStackOnce returns nil.
+func WithStackOnce(err error) error {
+	return EnsureStackAt(err, 1)
+}
+
+// StackMsg annotates err with a stack trace at the point StackMsg was called and the
+// message, such that callers don't need to chain Stack() and Wrap() to add context.
+// If err is nil, StackMsg returns nil.
+func StackMsg(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&stack{
+		error: err,
+		Trace: callstack.Capture(1),
+		msg:   msg,
+	})
+}
+
+// StackMsgf is identical to StackMsg but formats the message
+func StackMsgf(err error, format string, a ...any) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&stack{
+		error: err,
+		Trace: callstack.Capture(1),
+		msg:   fmt.Sprintf(format, a...),
+	})
+}
+
+// StripStack returns a copy of err where the stack traces of the wrapper types from
+// this package in the chain are removed, for errors which are about to be serialized
+// into a customer visible payload where file and line information must not leak.
+// Messages, fields and the other annotations are retained.
+//
+// Errors in the chain which are not from this package cannot be copied, as such
+// stripping stops at the first foreign error, and any stack trace it carries remains.
+func StripStack(err error) error {
+	if w, ok := err.(wrapper); ok {
+		return w.stripStack()
+	}
+	return err
+}
+
+// stripAll returns a copy of errs where the stack traces are stripped from each error
+func stripAll(errs []error) []error {
+	result := make([]error, len(errs))
+	for i, err := range errs {
+		result[i] = StripStack(err)
+	}
+	return result
 }
 
 type stack struct {
 	error
-	*callstack.CallStack
+	callstack.Trace
+	msg string
+}
+
+func (w *stack) Error() string {
+	if w.msg == NoMsg {
+		return limitMsg(w.error.Error())
+	}
+	return limitMsg(w.msg + ": " + w.error.Error())
 }
 
 func (w *stack) Unwrap() error { return w.error }
 
+func (w *stack) ownFields() map[string]any { return nil }
+
+func (w *stack) message() string { return w.msg }
+
+func (w *stack) clone() error {
+	c := *w
+	c.error = Clone(w.error)
+	return &c
+}
+
+func (w *stack) stripStack() error {
+	c := *w
+	c.Trace = callstack.Trace{}
+	c.error = StripStack(w.error)
+	return &c
+}
+
 func (w *stack) Is(target error) bool {
 	_, ok := target.(*stack)
-	return ok
+	return ok && MatchWrapperType
 }
 
 // Cause returns the wrapped error which was the original
@@ -40,30 +142,30 @@ func (w *stack) Is(target error) bool {
 func (w *stack) Cause() error { return w.error }
 
 func (w *stack) HasFields() map[string]any {
-	if child, ok := w.error.(HasFields); ok {
-		return child.HasFields()
+	if result := mergeFields(w); len(result) != 0 {
+		return result
 	}
-
-	var f HasFields
-	if errors.As(w.error, &f) {
-		return f.HasFields()
-	}
-
 	return nil
 }
 
+func (w *stack) writePlus(out io.Writer) bool {
+	var b strings.Builder
+	formatPlus(&b, w.msg, w.Unwrap(), "")
+	_, _ = io.WriteString(out, withFrames(b.String(), fmt.Sprintf("%+v", w.Trace)))
+	return true
+}
+
 func (w *stack) Format(s fmt.State, verb rune) {
 	switch verb {
 	case 'v':
 		if s.Flag('+') {
-			_, _ = fmt.Fprintf(s, "%+v", w.Unwrap())
-			w.CallStack.Format(s, verb)
+			w.writePlus(s)
 			return
 		}
 		fallthrough
 	case 's':
-		_, _ = io.WriteString(s, w.Error())
+		_, _ = io.WriteString(s, MessageOf(w))
 	case 'q':
-		_, _ = fmt.Fprintf(s, "%q", w.Error())
+		_, _ = fmt.Fprintf(s, "%q", MessageOf(w))
 	}
 }
@@ -167,3 +167,134 @@ func pkgErrorCause(err error) error {
 	}
 	return err
 }
+
+func deepStack(depth int) error {
+	if depth == 0 {
+		return errors.Stack(io.EOF)
+	}
+	return deepStack(depth - 1)
+}
+
+func TestStackElidedFrames(t *testing.T) {
+	t.Run("shallow stack reports no elided frames", func(t *testing.T) {
+		err := errors.Stack(io.EOF)
+		assert.NotContains(t, fmt.Sprintf("%+v", err), "more frames elided")
+		_, ok := errors.ToMap(err)["excFramesElided"]
+		assert.False(t, ok)
+	})
+
+	t.Run("deep stack reports elided frames", func(t *testing.T) {
+		err := deepStack(50)
+		assert.Regexp(t, `\.\.\. \d+ more frames elided$`, fmt.Sprintf("%+v", err))
+
+		m := errors.ToMap(errors.Wrap(err, "wrapped"))
+		assert.Greater(t, m["excFramesElided"], 18)
+	})
+}
+
+func TestStackMsg(t *testing.T) {
+	inner := errors.Wrap(io.EOF, "inner")
+	err := errors.StackMsg(inner, "while reading config")
+	assert.Equal(t, "while reading config: inner: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	var s callstack.HasStackTrace
+	assert.True(t, errors.As(err, &s))
+	assert.Equal(t, 197, callstack.GetLastFrame(s.StackTrace()).LineNo)
+
+	err = errors.StackMsgf(io.EOF, "while reading %s", "config")
+	assert.Equal(t, "while reading config: EOF", err.Error())
+	assert.Regexp(t, `^while reading config: EOF\n.*TestStackMsg`, fmt.Sprintf("%+v", err))
+	assert.Equal(t, []string{"while reading config", "EOF"}, errors.ToMapOpts(err, errors.ToMapOptions{IncludeMessages: true})["excMessages"])
+
+	assert.NoError(t, errors.StackMsg(nil, "msg"))
+	assert.NoError(t, errors.StackMsgf(nil, "msg"))
+}
+
+func TestStripStack(t *testing.T) {
+	err := errors.WrapOpts(
+		errors.Fields{"key1": "value1"}.Wrap(errors.Stack(io.EOF), "while reading"),
+		"while fetching",
+		errors.WithCode("fetch.failed"),
+	)
+	stripped := errors.StripStack(err)
+	assert.Equal(t, err.Error(), stripped.Error())
+	assert.True(t, errors.Is(stripped, io.EOF))
+
+	m := errors.ToMap(stripped)
+	assert.Equal(t, "value1", m["key1"])
+	assert.Empty(t, m["excFileName"])
+	assert.Empty(t, m["excLineNum"])
+	assert.Empty(t, errors.ToEnvelope(stripped).Frames)
+	assert.Equal(t, "fetch.failed", errors.ToEnvelope(stripped).Code)
+	assert.NotContains(t, fmt.Sprintf("%+v", stripped), "stack_test.go")
+
+	// The original error is not modified
+	assert.Contains(t, errors.ToMap(err)["excFileName"], "stack_test.go")
+	assert.NoError(t, errors.StripStack(nil))
+}
+
+func ensureStackHelper(err error) error {
+	return errors.EnsureStackAt(err, 1)
+}
+
+func TestEnsureStackAt(t *testing.T) {
+	err := errors.EnsureStackAt(io.EOF, 0)
+	assert.Equal(t, "EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	caller, ok := errors.Caller(err)
+	assert.True(t, ok)
+	assert.Equal(t, "errors_test.TestEnsureStackAt", caller.Func)
+
+	// The chain already has a stack trace
+	wrapped := errors.Wrap(io.EOF, "while reading")
+	assert.Equal(t, wrapped, errors.EnsureStackAt(wrapped, 0))
+
+	// Skip reports the caller of the helper
+	caller, ok = errors.Caller(ensureStackHelper(io.EOF))
+	assert.True(t, ok)
+	assert.Equal(t, "errors_test.TestEnsureStackAt", caller.Func)
+
+	assert.NoError(t, errors.EnsureStackAt(nil, 0))
+}
+
+func TestWithStackOnce(t *testing.T) {
+	err := errors.WithStackOnce(io.EOF)
+	assert.Equal(t, "EOF", err.Error())
+	caller, ok := errors.Caller(err)
+	assert.True(t, ok)
+	assert.Equal(t, "errors_test.TestWithStackOnce", caller.Func)
+
+	wrapped := errors.Wrap(io.EOF, "while reading")
+	assert.Equal(t, wrapped, errors.WithStackOnce(wrapped))
+	foreign := fmt.Errorf("while loading: %w", wrapped)
+	assert.Equal(t, foreign, errors.WithStackOnce(foreign))
+	assert.NoError(t, errors.WithStackOnce(nil))
+}
+
+func TestStripStackPanicError(t *testing.T) {
+	var recovered error
+	func() {
+		defer func() { recovered, _ = recover().(error) }()
+		errors.Must(0, io.EOF)
+	}()
+	assert.True(t, errors.IsWrapped(recovered))
+
+	stripped := errors.StripStack(recovered)
+	assert.Equal(t, "EOF", stripped.Error())
+	assert.Empty(t, errors.ToEnvelope(stripped).Frames)
+	assert.NotEmpty(t, errors.ToEnvelope(recovered).Frames)
+
+	c := errors.Clone(recovered)
+	assert.NotSame(t, recovered, c)
+	assert.True(t, errors.Is(c, io.EOF))
+}
+
+func TestElidedFramesSurviveCopies(t *testing.T) {
+	err := errors.Wrap(deepStack(50), "wrapped")
+	elided := errors.ToMap(err)["excFramesElided"]
+	assert.NotNil(t, elided)
+
+	assert.Equal(t, elided, errors.ToMap(errors.Clone(err))["excFramesElided"])
+	assert.Equal(t, elided, errors.ToMap(errors.Fields{"key": "value"}.Wrap(err, "fields"))["excFramesElided"])
+}
@@ -0,0 +1,111 @@
+package errors
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Translation is the customer visible form of an internal error
+type Translation struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+// Translator maps internal errors to the Translation which is safe to return to
+// customers. Errors are matched against the registered sentinel errors using Is(),
+// in the order they were registered, then against the registered Kinds.
+type Translator struct {
+	mu        sync.RWMutex
+	sentinels []*sentinelTranslation
+	kinds     map[Kind]Translation
+	fallback  Translation
+}
+
+type sentinelTranslation struct {
+	target error
+	t      Translation
+}
+
+// NewTranslator returns a Translator which uses the fallback translation for
+// errors which do not match any of the registered mappings.
+func NewTranslator(fallback Translation) *Translator {
+	return &Translator{
+		kinds:    map[Kind]Translation{},
+		fallback: fallback,
+	}
+}
+
+// DefaultTranslator is the Translator used by Public()
+var DefaultTranslator = NewTranslator(Translation{
+	Code:    "internal",
+	Message: "internal error",
+	Status:  http.StatusInternalServerError,
+})
+
+// RegisterError maps any error which matches the target according to Is() to t.
+// It returns a function which removes the mapping.
+//
+//	errors.DefaultTranslator.RegisterError(sql.ErrNoRows, errors.Translation{
+//		Code:    "not_found",
+//		Message: "resource not found",
+//		Status:  http.StatusNotFound,
+//	})
+func (tr *Translator) RegisterError(target error, t Translation) (remove func()) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	entry := &sentinelTranslation{target: target, t: t}
+	tr.sentinels = append(tr.sentinels, entry)
+	return func() {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		tr.sentinels = without(tr.sentinels, entry)
+	}
+}
+
+// RegisterKind maps any error with the Kind (see WithKind()) to t.
+// It returns a function which restores the previous mapping.
+func (tr *Translator) RegisterKind(kind Kind, t Translation) (remove func()) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return put(&tr.mu, tr.kinds, kind, t)
+}
+
+// Translate returns the Translation for err and true, or the fallback
+// translation and false if err does not match any registered mapping.
+func (tr *Translator) Translate(err error) (Translation, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	for _, s := range tr.sentinels {
+		if Is(err, s.target) {
+			return s.t, true
+		}
+	}
+	if t, ok := tr.kinds[KindOf(err)]; ok {
+		return t, true
+	}
+	return tr.fallback, false
+}
+
+// Public returns a new error which carries only the translated code, message and
+// status of err, along with the ID of err such that the customer visible error can
+// be correlated with the logged internal error. The messages, fields and stack trace
+// of the internal chain are not included.
+// If err is nil, Public returns nil.
+func (tr *Translator) Public(err error) error {
+	if err == nil {
+		return nil
+	}
+	t, _ := tr.Translate(err)
+	return WrapOpts(New(t.Message), NoMsg,
+		WithCode(t.Code),
+		WithStatus(t.Status),
+		WithID(IDOf(err)),
+		NoStack(),
+	)
+}
+
+// Public translates err using the DefaultTranslator, see Translator.Public()
+func Public(err error) error {
+	return DefaultTranslator.Public(err)
+}
@@ -0,0 +1,76 @@
+package errors_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslator(t *testing.T) {
+	tr := errors.NewTranslator(errors.Translation{Code: "internal", Message: "internal error", Status: 500})
+	tr.RegisterError(io.EOF, errors.Translation{Code: "not_found", Message: "resource not found", Status: 404})
+	tr.RegisterKind("throttled", errors.Translation{Code: "rate_limited", Message: "slow down", Status: 429})
+
+	internal := errors.WrapOpts(io.EOF, "while querying db",
+		errors.WithID("req-1"),
+		errors.WithFieldsOpt(errors.Fields{"query": "SELECT * FROM users"}),
+	)
+	public := tr.Public(internal)
+	require.Error(t, public)
+	assert.Equal(t, "resource not found", public.Error())
+	assert.False(t, errors.Is(public, io.EOF))
+	assert.NotContains(t, errors.ToMap(public), "query")
+	assert.NotContains(t, errors.ToMap(public), "excFuncName")
+
+	env := errors.ToEnvelope(public)
+	assert.Equal(t, "not_found", env.Code)
+	assert.Equal(t, 404, env.Status)
+	assert.Equal(t, "req-1", env.ID)
+
+	t.Run("Kind", func(t *testing.T) {
+		env := errors.ToEnvelope(tr.Public(errors.WrapOpts(io.ErrClosedPipe, "limiter", errors.WithKind("throttled"))))
+		assert.Equal(t, "rate_limited", env.Code)
+		assert.Equal(t, "slow down", env.Message)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		tn, ok := tr.Translate(errors.New("connection to 10.0.0.1 refused"))
+		assert.False(t, ok)
+		assert.Equal(t, "internal", tn.Code)
+	})
+
+	assert.NoError(t, tr.Public(nil))
+}
+
+func TestTranslatorRemove(t *testing.T) {
+	tr := errors.NewTranslator(errors.Translation{Code: "internal", Message: "internal error", Status: 500})
+	removeErr := tr.RegisterError(io.EOF, errors.Translation{Code: "not_found", Status: 404})
+	removeKind := tr.RegisterKind("throttled", errors.Translation{Code: "rate_limited", Status: 429})
+	restore := tr.RegisterKind("throttled", errors.Translation{Code: "slow_down", Status: 429})
+
+	tn, _ := tr.Translate(errors.WrapOpts(io.ErrClosedPipe, "limiter", errors.WithKind("throttled")))
+	assert.Equal(t, "slow_down", tn.Code)
+	restore()
+	tn, _ = tr.Translate(errors.WrapOpts(io.ErrClosedPipe, "limiter", errors.WithKind("throttled")))
+	assert.Equal(t, "rate_limited", tn.Code)
+	removeKind()
+	_, ok := tr.Translate(errors.WrapOpts(io.ErrClosedPipe, "limiter", errors.WithKind("throttled")))
+	assert.False(t, ok)
+
+	_, ok = tr.Translate(errors.Wrap(io.EOF, "while reading"))
+	assert.True(t, ok)
+	removeErr()
+	_, ok = tr.Translate(errors.Wrap(io.EOF, "while reading"))
+	assert.False(t, ok)
+}
+
+func TestPublic(t *testing.T) {
+	env := errors.ToEnvelope(errors.Public(errors.New("disk /dev/sda1 is full")))
+	assert.Equal(t, "internal error", env.Message)
+	assert.Equal(t, "internal", env.Code)
+	assert.Equal(t, http.StatusInternalServerError, env.Status)
+}
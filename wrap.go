@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mailgun/errors/callstack"
 )
@@ -13,11 +16,12 @@ func Wrap(err error, msg string) error {
 	if err == nil {
 		return nil
 	}
-	return &wrappedError{
-		stack:   callstack.New(1),
+	return observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
 		wrapped: err,
 		msg:     msg,
-	}
+	})
 }
 
 // Wrapf is identical to Wrap but formats the error before wrapping.
@@ -25,11 +29,184 @@ func Wrapf(err error, format string, a ...any) error {
 	if err == nil {
 		return nil
 	}
-	return &wrappedError{
-		stack:   callstack.New(1),
+	return observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
 		wrapped: err,
 		msg:     fmt.Sprintf(format, a...),
+	})
+}
+
+// WrapLazyf is identical to Wrapf but the arguments are returned by args, which is
+// only called when the message is first rendered. Use it when computing the arguments
+// is expensive, such as dumping a large struct, and the error is often discarded
+// without being logged. If err is nil, args is never called.
+//
+//	return errors.WrapLazyf(err, "while applying %s", func() []any { return []any{spew.Sdump(req)} })
+//
+// As args is called later, possibly from another goroutine, it must not depend on
+// state which is modified after WrapLazyf returns.
+func WrapLazyf(err error, format string, args func() []any) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		lazy:    &lazyMsg{format: format, args: args},
+	})
+}
+
+// Annotate adds a message to err without capturing a stack trace. Use it in tight
+// loops and intermediate layers where err already carries a stack trace and
+// capturing another is pure overhead.
+// If err is nil, Annotate returns nil.
+func Annotate(err error, msg string) error {
+	if err == nil {
+		return nil
 	}
+	return &annotation{wrapped: err, msg: msg}
+}
+
+// Annotatef is identical to Annotate but formats the message
+func Annotatef(err error, format string, a ...any) error {
+	if err == nil {
+		return nil
+	}
+	return &annotation{wrapped: err, msg: fmt.Sprintf(format, a...)}
+}
+
+// WrapCaller is identical to Wrap but only records the frame of the caller instead
+// of the full stack trace. Use it in hot paths where capturing the full stack is too
+// expensive, but the location of the error should still be reported.
+func WrapCaller(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&wrappedError{
+		Trace:   callstack.Trace{CallStack: callstack.NewCaller(1)},
+		created: NowFunc(),
+		wrapped: err,
+		msg:     msg,
+	})
+}
+
+// DeferWrap wraps the error pointed to by errp with a stack trace and the supplied
+// message if it is non-nil. It is intended to be used with a named return value
+// in a defer statement.
+//
+//	func (s *Store) Close() (err error) {
+//		defer errors.DeferWrap(&err, "while closing store")
+//		...
+//	}
+func DeferWrap(errp *error, msg string) {
+	if *errp == nil {
+		return
+	}
+	*errp = observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: *errp,
+		msg:     msg,
+	})
+}
+
+// DeferWrapf is identical to DeferWrap but formats the message
+func DeferWrapf(errp *error, format string, a ...any) {
+	if *errp == nil {
+		return
+	}
+	*errp = observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: *errp,
+		msg:     fmt.Sprintf(format, a...),
+	})
+}
+
+// WrapFn calls fn and wraps any error it returns with a stack trace to the caller of
+// WrapFn and the supplied message. If fn returns nil, WrapFn returns nil.
+//
+//	if err := errors.WrapFn("while loading config", cfg.Load); err != nil {
+//		return err
+//	}
+func WrapFn(msg string, fn func() error) error {
+	err := fn()
+	if err == nil {
+		return nil
+	}
+	return observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		msg:     msg,
+	})
+}
+
+// WrapFn1 is identical to WrapFn but for functions which return a value and an error.
+// The value returned by fn is returned unchanged, even if fn returns an error.
+//
+//	user, err := errors.WrapFn1("while fetching user", func() (User, error) {
+//		return store.Get(id)
+//	})
+func WrapFn1[T any](msg string, fn func() (T, error)) (T, error) {
+	v, err := fn()
+	if err == nil {
+		return v, nil
+	}
+	return v, observeWrap(&wrappedError{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		msg:     msg,
+	})
+}
+
+// CloseJoin closes the closer and joins any error returned by Close() into the error
+// pointed to by errp. The close error is wrapped with a stack trace and the optional
+// fields provided as alternating key/value pairs (see WrapKV()), such that the resource
+// which failed to close can be identified. It is intended to be used with a named
+// return value in a defer statement.
+//
+//	func readConfig(name string) (err error) {
+//		f, err := os.Open(name)
+//		if err != nil {
+//			return err
+//		}
+//		defer errors.CloseJoin(&err, f, "file", name)
+//		...
+//	}
+func CloseJoin(errp *error, closer io.Closer, kv ...any) {
+	err := closer.Close()
+	if err == nil {
+		return
+	}
+	AppendInto(errp, observeWrap(&fields{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		wrapped: err,
+		msg:     "while closing",
+		fields:  kvToSet(kv),
+	}))
+}
+
+// AppendInto joins err into the error pointed to by errp and returns true if err
+// was non-nil. If errp points to a nil error, it is replaced with err.
+//
+//	for _, item := range items {
+//		errors.AppendInto(&err, process(item))
+//	}
+func AppendInto(errp *error, err error) bool {
+	if err == nil {
+		return false
+	}
+	if *errp == nil {
+		*errp = err
+		return true
+	}
+	*errp = errors.Join(*errp, err)
+	return true
 }
 
 // Cause returns the last error in the stack of wrapped errors.
@@ -45,17 +222,34 @@ func Cause(err error) error {
 
 type wrappedError struct {
 	msg     string
+	lazy    *lazyMsg
 	wrapped error
-	stack   *callstack.CallStack
+	callstack.Trace
+	created time.Time
 }
 
 func (e *wrappedError) Unwrap() error {
 	return e.wrapped
 }
 
+func (e *wrappedError) ownFields() map[string]any { return nil }
+
+func (e *wrappedError) clone() error {
+	c := *e
+	c.wrapped = Clone(e.wrapped)
+	return &c
+}
+
+func (e *wrappedError) stripStack() error {
+	c := *e
+	c.Trace = callstack.Trace{}
+	c.wrapped = StripStack(e.wrapped)
+	return &c
+}
+
 func (e *wrappedError) Is(target error) bool {
 	_, ok := target.(*wrappedError)
-	return ok
+	return ok && MatchWrapperType
 }
 
 // Cause returns the wrapped error which was the original
@@ -66,19 +260,395 @@ func (e *wrappedError) Is(target error) bool {
 func (e *wrappedError) Cause() error { return e.wrapped }
 
 func (e *wrappedError) Error() string {
-	if e.msg == NoMsg {
-		return e.wrapped.Error()
+	msg := e.message()
+	if msg == NoMsg {
+		return limitMsg(e.wrapped.Error())
 	}
-	return e.msg + ": " + e.wrapped.Error()
+	return limitMsg(msg + ": " + e.wrapped.Error())
+}
+
+// message returns the message of the wrapper, rendering it first if it was created by WrapLazyf()
+func (e *wrappedError) message() string {
+	if e.lazy != nil {
+		return e.lazy.String()
+	}
+	return e.msg
 }
 
 func (e *wrappedError) StackTrace() callstack.StackTrace {
 	if child, ok := e.wrapped.(callstack.HasStackTrace); ok {
 		return child.StackTrace()
 	}
-	return e.stack.StackTrace()
+	return e.Trace.StackTrace()
+}
+
+func (e *wrappedError) CreatedAt() time.Time {
+	return e.created
 }
 
 func (e *wrappedError) Format(s fmt.State, verb rune) {
-	_, _ = io.WriteString(s, e.Error())
+	if verb == 'v' && s.Flag('+') {
+		e.writePlus(s)
+		return
+	}
+	_, _ = io.WriteString(s, MessageOf(e))
+}
+
+func (e *wrappedError) writePlus(w io.Writer) bool {
+	if j, ok := e.wrapped.(interface{ Unwrap() []error }); ok {
+		_, _ = io.WriteString(w, e.message())
+		return tree(j.Unwrap()).writePlus(w)
+	}
+	_, _ = io.WriteString(w, MessageOf(e))
+	return false
+}
+
+// lazyMsg is the message of an error created by WrapLazyf(), it is rendered at most once
+type lazyMsg struct {
+	once   sync.Once
+	format string
+	args   func() []any
+	msg    string
+}
+
+func (l *lazyMsg) String() string {
+	l.once.Do(func() {
+		l.msg = fmt.Sprintf(l.format, l.args()...)
+		l.args = nil
+	})
+	return l.msg
+}
+
+// formattedError is returned by Errorf() when the format has exactly one %w verb
+type formattedError struct {
+	msg     string
+	wrapped error
+	callstack.Trace
+}
+
+func (e *formattedError) Unwrap() error {
+	return e.wrapped
+}
+
+func (e *formattedError) ownFields() map[string]any { return nil }
+
+// message returns the whole formatted message, as the message of the wrapped
+// error might be anywhere within it and cannot be separated.
+func (e *formattedError) message() string { return e.msg }
+
+func (e *formattedError) clone() error {
+	c := *e
+	c.wrapped = Clone(e.wrapped)
+	return &c
+}
+
+func (e *formattedError) stripStack() error {
+	c := *e
+	c.Trace = callstack.Trace{}
+	c.wrapped = StripStack(e.wrapped)
+	return &c
+}
+
+func (e *formattedError) Is(target error) bool {
+	_, ok := target.(*formattedError)
+	return ok && MatchWrapperType
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (e *formattedError) Cause() error { return e.wrapped }
+
+func (e *formattedError) Error() string {
+	return limitMsg(e.msg)
+}
+
+func (e *formattedError) StackTrace() callstack.StackTrace {
+	if child, ok := e.wrapped.(callstack.HasStackTrace); ok {
+		return child.StackTrace()
+	}
+	return e.Trace.StackTrace()
+}
+
+func (e *formattedError) Format(s fmt.State, verb rune) {
+	_, _ = io.WriteString(s, MessageOf(e))
+}
+
+// formattedMsg is returned by Errorf() when the format has no %w verb. As it wraps no
+// error, it has no Unwrap() or Cause(), such that github.com/pkg/errors.Cause() returns it.
+type formattedMsg struct {
+	msg string
+	callstack.Trace
+}
+
+func (e *formattedMsg) ownFields() map[string]any { return nil }
+
+func (e *formattedMsg) message() string { return e.msg }
+
+func (e *formattedMsg) clone() error {
+	c := *e
+	return &c
+}
+
+func (e *formattedMsg) stripStack() error {
+	return &formattedMsg{msg: e.msg}
+}
+
+func (e *formattedMsg) Is(target error) bool {
+	_, ok := target.(*formattedMsg)
+	return ok && MatchWrapperType
+}
+
+func (e *formattedMsg) Error() string {
+	return limitMsg(e.msg)
+}
+
+func (e *formattedMsg) StackTrace() callstack.StackTrace {
+	return e.Trace.StackTrace()
+}
+
+func (e *formattedMsg) Format(s fmt.State, verb rune) {
+	_, _ = io.WriteString(s, MessageOf(e))
+}
+
+// annotation is returned by Annotate(), it carries only the message such that, unlike
+// the wrappers returned by WrapOpts(), it does not shadow the code, status, kind or id
+// of the errors it wraps when using As().
+type annotation struct {
+	msg     string
+	wrapped error
+}
+
+func (e *annotation) Unwrap() error {
+	return e.wrapped
+}
+
+func (e *annotation) ownFields() map[string]any { return nil }
+
+func (e *annotation) message() string { return e.msg }
+
+func (e *annotation) clone() error {
+	return &annotation{msg: e.msg, wrapped: Clone(e.wrapped)}
+}
+
+func (e *annotation) stripStack() error {
+	return &annotation{msg: e.msg, wrapped: StripStack(e.wrapped)}
+}
+
+func (e *annotation) Is(target error) bool {
+	_, ok := target.(*annotation)
+	return ok && MatchWrapperType
+}
+
+// Cause returns the wrapped error which was the original
+// cause of the issue. We only support this because some code
+// depends on github.com/pkg/errors.Cause() returning the cause
+// of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (e *annotation) Cause() error { return e.wrapped }
+
+func (e *annotation) Error() string {
+	if e.msg == NoMsg {
+		return limitMsg(e.wrapped.Error())
+	}
+	return limitMsg(e.msg + ": " + e.wrapped.Error())
+}
+
+func (e *annotation) HasFields() map[string]any {
+	return mergeFields(e)
+}
+
+func (e *annotation) Format(s fmt.State, verb rune) {
+	_, _ = io.WriteString(s, MessageOf(e))
+}
+
+// formattedErrors is returned by Errorf() when the format has more than one %w verb
+type formattedErrors struct {
+	msg string
+	// own is the message with each %w verb left as is, such that the %+v format
+	// does not repeat the messages of the wrapped errors before the tree.
+	own     string
+	wrapped []error
+	callstack.Trace
+}
+
+func (e *formattedErrors) Unwrap() []error {
+	return e.wrapped
+}
+
+func (e *formattedErrors) ownFields() map[string]any { return nil }
+
+func (e *formattedErrors) message() string { return e.msg }
+
+func (e *formattedErrors) clone() error {
+	c := *e
+	c.wrapped = cloneAll(e.wrapped)
+	return &c
+}
+
+func (e *formattedErrors) stripStack() error {
+	c := *e
+	c.Trace = callstack.Trace{}
+	c.wrapped = stripAll(e.wrapped)
+	return &c
+}
+
+// Cause returns the first of the wrapped errors, as github.com/pkg/errors.Cause()
+// can only follow a single error. We only support this because some code depends
+// on github.com/pkg/errors.Cause() returning the cause of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (e *formattedErrors) Cause() error { return e.wrapped[0] }
+
+func (e *formattedErrors) Is(target error) bool {
+	_, ok := target.(*formattedErrors)
+	return ok && MatchWrapperType
+}
+
+func (e *formattedErrors) Error() string {
+	return limitMsg(e.msg)
+}
+
+func (e *formattedErrors) StackTrace() callstack.StackTrace {
+	return e.Trace.StackTrace()
+}
+
+func (e *formattedErrors) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		e.writePlus(s)
+		return
+	}
+	_, _ = io.WriteString(s, MessageOf(e))
+}
+
+func (e *formattedErrors) writePlus(w io.Writer) bool {
+	_, _ = io.WriteString(w, e.own)
+	return tree(e.wrapped).writePlus(w)
+}
+
+// ownMessage returns the message of fmt.Errorf(format, a...) where each %w verb is
+// written as is. The operands of the %w verbs are found by formatting the message with
+// each error replaced by a placeholder, and seeing which placeholders were wrapped.
+func ownMessage(format string, a ...any) string {
+	args := make([]any, len(a))
+	for i, arg := range a {
+		if err, ok := arg.(error); ok {
+			args[i] = &placeholder{err: err}
+		} else {
+			args[i] = arg
+		}
+	}
+	if j, ok := fmt.Errorf(format, args...).(interface{ Unwrap() []error }); ok {
+		for _, err := range j.Unwrap() {
+			if p, ok := err.(*placeholder); ok {
+				p.verb = true
+			}
+		}
+	}
+	return fmt.Errorf(format, args...).Error()
+}
+
+// placeholder formats as the error it replaces, or as a %w verb if it was wrapped
+type placeholder struct {
+	err  error
+	verb bool
+}
+
+func (p *placeholder) Error() string {
+	return p.err.Error()
+}
+
+func (p *placeholder) Format(s fmt.State, verb rune) {
+	if p.verb {
+		_, _ = io.WriteString(s, "%w")
+		return
+	}
+	_, _ = fmt.Fprintf(s, fmt.FormatString(s, verb), p.err)
+}
+
+// tree formats each of the errors using %+v as a branch on a new line, indented
+// beneath the line of the parent, such that nested trees remain unambiguous. The
+// stack trace of each branch is included in its block, even if the branch was
+// created by a wrapper which does not print its stack trace, such as Wrap().
+type tree []error
+
+func (t tree) Format(s fmt.State, _ rune) {
+	t.writePlus(s)
+}
+
+// writePlus writes each of the branches, it returns false as the stack traces of the
+// branches are not those of the error which wraps the tree.
+func (t tree) writePlus(w io.Writer) bool {
+	for _, err := range t {
+		var b strings.Builder
+		printed := writePlus(&b, err)
+		branch := b.String()
+		if trace, ok := StackOf(err); ok && !printed && len(trace) != 0 {
+			branch = withFrames(branch, fmt.Sprintf("%+v", trace))
+		}
+		lines := strings.Split(branch, "\n")
+		_, _ = io.WriteString(w, strings.TrimRight("\n  - "+lines[0], " "))
+		for _, line := range lines[1:] {
+			_, _ = io.WriteString(w, "\n    "+line)
+		}
+	}
+	return false
+}
+
+// plusFormatter is implemented by the error types of this package whose %+v format
+// might include a stack trace, such that a tree knows whether a branch included one.
+type plusFormatter interface {
+	// writePlus writes the %+v format of the error to w, and returns true if it
+	// includes the stack trace of the error. Stack traces within the branches of
+	// a tree are not counted, as they are those of the branches.
+	writePlus(w io.Writer) bool
+}
+
+// writePlus writes the %+v format of err to w, and returns true if it includes the stack trace of err
+func writePlus(w io.Writer, err error) bool {
+	if p, ok := plusV(err).(plusFormatter); ok {
+		return p.writePlus(w)
+	}
+	_, _ = fmt.Fprintf(w, "%+v", err)
+	return false
+}
+
+// withFrames returns the %+v format of an error with the frames of its stack trace,
+// which are placed before the tree of joined errors if it has one, such that they
+// cannot be mistaken for the frames of the last branch.
+func withFrames(text, frames string) string {
+	if i := strings.Index(text, "\n  -"); i != -1 {
+		return text[:i] + frames + text[i:]
+	}
+	return text + frames
+}
+
+// formatPlus writes the %+v format of a wrapper with msg and suffix, such as the fields
+// attached by the wrapper. If wrapped was created using Join(), msg and suffix are written
+// on the first line followed by each of the joined errors as a tree. It returns true if
+// the format includes a stack trace.
+func formatPlus(w io.Writer, msg string, wrapped error, suffix string) bool {
+	if t, ok := plusV(wrapped).(tree); ok {
+		_, _ = io.WriteString(w, strings.TrimLeft(msg+suffix, " "))
+		return t.writePlus(w)
+	}
+	if msg != NoMsg {
+		_, _ = io.WriteString(w, msg+": ")
+	}
+	trace := writePlus(w, wrapped)
+	_, _ = io.WriteString(w, suffix)
+	return trace
+}
+
+// plusV returns err such that formatting it using %+v prints errors joined using
+// Join() as a tree, as the standard library join error does not implement fmt.Formatter.
+func plusV(err error) any {
+	if _, ok := err.(fmt.Formatter); ok {
+		return err
+	}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return tree(j.Unwrap())
+	}
+	return err
 }
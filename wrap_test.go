@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/mailgun/errors"
+	"github.com/mailgun/errors/callstack"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -139,3 +141,313 @@ func TestWrappedCause(t *testing.T) {
 	err := errors.Wrap(io.EOF, "message")
 	assert.Equal(t, io.EOF, pkgErrorCause(err))
 }
+
+func TestErrorfStack(t *testing.T) {
+	t.Run("without %w", func(t *testing.T) {
+		err := errors.Errorf("error %d", 1)
+		assert.Equal(t, "error 1", err.Error())
+		assert.Nil(t, errors.Unwrap(err))
+		assert.Equal(t, err, pkgErrorCause(err))
+
+		m := errors.ToMap(err)
+		assert.Equal(t, "errors_test.TestErrorfStack.func1", m["excFuncName"])
+		assert.Regexp(t, ".*/wrap_test.go", m["excFileName"])
+	})
+
+	t.Run("single %w", func(t *testing.T) {
+		err := errors.Errorf("wrapped: %w", &ErrTest{Msg: "query error"})
+		assert.Equal(t, "wrapped: query error", err.Error())
+		assert.Equal(t, &ErrTest{Msg: "query error"}, errors.Unwrap(err))
+		assert.True(t, errors.Is(err, &ErrTest{}))
+		assert.Equal(t, &ErrTest{Msg: "query error"}, pkgErrorCause(err))
+
+		m := errors.ToMap(err)
+		assert.Equal(t, "errors_test.TestErrorfStack.func2", m["excFuncName"])
+		assert.Equal(t, "*errors_test.ErrTest", m["excType"])
+	})
+
+	t.Run("single %w reports the stack of the operand", func(t *testing.T) {
+		wrapped := errors.Wrap(io.EOF, "inner")
+		err := errors.Errorf("outer: %w", wrapped)
+		assert.Equal(t, errors.ToMap(wrapped)["excLineNum"], errors.ToMap(err)["excLineNum"])
+	})
+
+	t.Run("multiple %w", func(t *testing.T) {
+		err := errors.Errorf("%w and %w", io.EOF, &ErrTest{Msg: "query error"})
+		assert.Equal(t, "EOF and query error", err.Error())
+		assert.True(t, errors.Is(err, io.EOF))
+		assert.True(t, errors.Is(err, &ErrTest{}))
+
+		multi, ok := err.(interface{ Unwrap() []error })
+		require.True(t, ok)
+		assert.Equal(t, []error{io.EOF, &ErrTest{Msg: "query error"}}, multi.Unwrap())
+
+		assert.Equal(t, io.EOF, pkgErrorCause(err))
+
+		m := errors.ToMap(err)
+		assert.Equal(t, "errors_test.TestErrorfStack.func4", m["excFuncName"])
+	})
+
+	t.Run("nil %w operands", func(t *testing.T) {
+		err := errors.Errorf("%w and %w", nil, nil)
+		assert.Nil(t, errors.Unwrap(err))
+		assert.Equal(t, err, pkgErrorCause(err))
+
+		err = errors.Errorf("%w and %w", nil, io.EOF)
+		assert.Equal(t, io.EOF, errors.Unwrap(err))
+		assert.Equal(t, io.EOF, pkgErrorCause(err))
+	})
+}
+
+func deferWrap(err error) (result error) {
+	defer errors.DeferWrap(&result, "deferred")
+	return err
+}
+
+func deferWrapf(err error) (result error) {
+	defer errors.DeferWrapf(&result, "deferred '%d'", 1)
+	return err
+}
+
+func TestDeferWrap(t *testing.T) {
+	err := deferWrap(&ErrTest{Msg: "query error"})
+	assert.Equal(t, "deferred: query error", err.Error())
+	assert.True(t, errors.Is(err, &ErrTest{}))
+	assert.Equal(t, "errors_test.deferWrap", errors.ToMap(err)["excFuncName"])
+
+	err = deferWrapf(&ErrTest{Msg: "query error"})
+	assert.Equal(t, "deferred '1': query error", err.Error())
+	assert.Equal(t, "errors_test.deferWrapf", errors.ToMap(err)["excFuncName"])
+
+	assert.NoError(t, deferWrap(nil))
+	assert.NoError(t, deferWrapf(nil))
+}
+
+type closer struct {
+	err error
+}
+
+func (c *closer) Close() error {
+	return c.err
+}
+
+func closeJoin(err error, c io.Closer) (result error) {
+	defer errors.CloseJoin(&result, c, "resource", "db")
+	return err
+}
+
+func TestCloseJoin(t *testing.T) {
+	t.Run("close error is returned", func(t *testing.T) {
+		err := closeJoin(nil, &closer{err: io.ErrClosedPipe})
+		assert.Equal(t, "while closing: io: read/write on closed pipe", err.Error())
+		assert.True(t, errors.Is(err, io.ErrClosedPipe))
+
+		m := errors.ToMap(err)
+		assert.Equal(t, "db", m["resource"])
+		assert.Equal(t, "errors_test.closeJoin", m["excFuncName"])
+	})
+
+	t.Run("close error is joined with return error", func(t *testing.T) {
+		err := closeJoin(io.EOF, &closer{err: io.ErrClosedPipe})
+		assert.True(t, errors.Is(err, io.EOF))
+		assert.True(t, errors.Is(err, io.ErrClosedPipe))
+	})
+
+	t.Run("no close error", func(t *testing.T) {
+		assert.Equal(t, io.EOF, closeJoin(io.EOF, &closer{}))
+		assert.NoError(t, closeJoin(nil, &closer{}))
+	})
+}
+
+func TestAppendInto(t *testing.T) {
+	var err error
+	assert.False(t, errors.AppendInto(&err, nil))
+	assert.NoError(t, err)
+
+	assert.True(t, errors.AppendInto(&err, io.EOF))
+	assert.Equal(t, io.EOF, err)
+
+	assert.True(t, errors.AppendInto(&err, io.ErrClosedPipe))
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.True(t, errors.Is(err, io.ErrClosedPipe))
+}
+
+func TestWrapCaller(t *testing.T) {
+	err := errors.WrapCaller(&ErrTest{Msg: "query error"}, "message")
+	assert.Equal(t, "message: query error", err.Error())
+	assert.True(t, errors.Is(err, &ErrTest{}))
+
+	var stack callstack.HasStackTrace
+	require.True(t, errors.As(err, &stack))
+	assert.Len(t, stack.StackTrace(), 1)
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "errors_test.TestWrapCaller", m["excFuncName"])
+	assert.Regexp(t, ".*/wrap_test.go", m["excFileName"])
+
+	assert.Nil(t, errors.WrapCaller(nil, "message"))
+}
+
+func BenchmarkWrap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = errors.Wrap(io.EOF, "message")
+	}
+}
+
+func BenchmarkWrapKV(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = errors.WrapKV(io.EOF, "message", "user_id", 42, "region", "us-east")
+	}
+}
+
+func BenchmarkWrapCaller(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = errors.WrapCaller(io.EOF, "message")
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	inner := errors.Wrap(io.EOF, "inner")
+	err := errors.Annotate(inner, "while processing item")
+	assert.Equal(t, "while processing item: inner: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+
+	// The stack trace of the inner error is reported
+	caller, ok := errors.Caller(err)
+	require.True(t, ok)
+	assert.Equal(t, "errors_test.TestAnnotate", caller.Func)
+	assert.Equal(t, errors.ToMap(inner)["excLineNum"], errors.ToMap(err)["excLineNum"])
+
+	err = errors.Annotatef(io.EOF, "while processing item %d", 10)
+	assert.Equal(t, "while processing item 10: EOF", err.Error())
+	_, ok = errors.Caller(err)
+	assert.False(t, ok)
+
+	assert.NoError(t, errors.Annotate(nil, "msg"))
+	assert.NoError(t, errors.Annotatef(nil, "msg"))
+
+	// Annotate does not hide the code, status, kind or id from As()
+	err = errors.WrapOpts(io.EOF, "while fetching", errors.WithCode("user.not_found"))
+	var code errors.HasCode
+	require.True(t, errors.As(errors.Annotate(err, "while processing item"), &code))
+	assert.Equal(t, "user.not_found", code.Code())
+	var status errors.HasStatus
+	assert.False(t, errors.As(errors.Annotate(io.EOF, "while processing item"), &status))
+}
+
+func TestFormatTree(t *testing.T) {
+	first := errors.WrapOpts(io.EOF, "item 1", errors.WithFieldsOpt(errors.Fields{"item": 1}), errors.NoStack())
+	second := errors.WrapOpts(io.ErrClosedPipe, "item 2", errors.WithFieldsOpt(errors.Fields{"item": 2}), errors.NoStack())
+	nested := errors.Join(errors.New("nested 1"), errors.New("nested 2"))
+
+	err := errors.Wrap(errors.Join(first, second, nested), "while sending batch")
+	assert.Equal(t, "while sending batch\n"+
+		"  - item 1: EOF (item=1)\n"+
+		"  - item 2: io: read/write on closed pipe (item=2)\n"+
+		"  -\n"+
+		"      - nested 1\n"+
+		"      - nested 2", fmt.Sprintf("%+v", err))
+
+	// Errorf() with more than one %w does not repeat the messages of the tree on the first line
+	err = errors.Errorf("while sending: %w, %w", first, second)
+	assert.Equal(t, "while sending: %w, %w\n"+
+		"  - item 1: EOF (item=1)\n"+
+		"  - item 2: io: read/write on closed pipe (item=2)", fmt.Sprintf("%+v", err))
+
+	// Fields attached above the tree are on the first line of the tree
+	err = errors.Fields{"batch": "b1"}.Wrap(errors.Join(first), "while sending batch")
+	assert.Equal(t, "while sending batch (batch=b1)\n  - item 1: EOF (item=1)", fmt.Sprintf("%+v", err))
+}
+
+func TestFormatTreeStacks(t *testing.T) {
+	first := errors.Wrap(io.EOF, "item 1")
+	second := errors.Stack(io.ErrClosedPipe)
+
+	// The stack trace of the wrapper is printed before the tree, and the stack trace
+	// of each branch within its block
+	err := errors.StackMsg(errors.Join(first, second), "while sending batch")
+	out := fmt.Sprintf("%+v", err)
+	assert.Regexp(t, `^while sending batch\n`+
+		`github\.com/mailgun/errors_test\.TestFormatTreeStacks\n\t\S+wrap_test\.go:\d+\n(?s:.*)`+
+		`\n  - item 1: EOF\n`+
+		`    github\.com/mailgun/errors_test\.TestFormatTreeStacks\n    \t\S+wrap_test\.go:\d+\n(?s:.*)`+
+		`\n  - io: read/write on closed pipe\n`+
+		`    github\.com/mailgun/errors_test\.TestFormatTreeStacks\n    \t\S+wrap_test\.go:\d+\n`, out)
+	// A branch which prints its own stack trace does not print it twice
+	assert.Equal(t, 1, strings.Count(out, "wrap_test.go:"+strconv.Itoa(errors.ToMap(second)["excLineNum"].(int))))
+
+	// The stack trace of a branch is placed before the tree it wraps
+	err = errors.Wrap(errors.Join(errors.Wrap(errors.Join(io.EOF), "nested")), "while sending batch")
+	assert.Regexp(t, `^while sending batch\n  - nested\n    github\.com/mailgun/errors_test\.TestFormatTreeStacks\n(?s:.*)\n      - EOF$`,
+		fmt.Sprintf("%+v", err))
+}
+
+func TestFormatTreeStacksExact(t *testing.T) {
+	first := errors.Wrap(io.EOF, "item 1")
+	second := errors.Stack(io.ErrClosedPipe)
+	// frames returns the stack trace of err as printed within a branch of the tree
+	frames := func(err error) string {
+		trace, ok := errors.StackOf(err)
+		require.True(t, ok)
+		return strings.ReplaceAll(fmt.Sprintf("%+v", trace), "\n", "\n    ")
+	}
+	branches := "\n  - item 1: EOF" + frames(first) +
+		"\n  - io: read/write on closed pipe" + frames(second)
+
+	assert.Equal(t, "while sending batch"+branches,
+		fmt.Sprintf("%+v", errors.Wrap(errors.Join(first, second), "while sending batch")))
+	assert.Equal(t, "while sending %w and %w"+branches,
+		fmt.Sprintf("%+v", errors.Errorf("while sending %w and %w", first, second)))
+	// Errors which are not wrapped are formatted as usual
+	assert.Equal(t, "while sending 2 items: %w, %w (\"EOF\")"+branches,
+		fmt.Sprintf("%+v", errors.Errorf("while sending %d items: %w, %w (%q)", 2, first, second, io.EOF)))
+}
+
+func TestWrapFn(t *testing.T) {
+	err := errors.WrapFn("while loading", func() error { return io.EOF })
+	require.Error(t, err)
+	assert.Equal(t, "while loading: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "errors_test.TestWrapFn", errors.ToMap(err)["excFuncName"])
+
+	assert.NoError(t, errors.WrapFn("while loading", func() error { return nil }))
+}
+
+func TestWrapFn1(t *testing.T) {
+	v, err := errors.WrapFn1("while parsing", func() (int, error) { return strconv.Atoi("42") })
+	require.NoError(t, err)
+	assert.Equal(t, 42, v)
+
+	v, err = errors.WrapFn1("while parsing", func() (int, error) { return -1, io.EOF })
+	require.Error(t, err)
+	assert.Equal(t, -1, v)
+	assert.Equal(t, "while parsing: EOF", err.Error())
+	assert.Equal(t, "errors_test.TestWrapFn1", errors.ToMap(err)["excFuncName"])
+}
+
+func TestWrapLazyf(t *testing.T) {
+	var calls int
+	args := func() []any {
+		calls++
+		return []any{"config.yaml", 3}
+	}
+
+	err := errors.WrapLazyf(io.EOF, "while reading '%s' attempt %d", args)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, "while reading 'config.yaml' attempt 3: EOF", err.Error())
+	assert.Equal(t, "while reading 'config.yaml' attempt 3: EOF", fmt.Sprintf("%v", err))
+	assert.Equal(t, 1, calls)
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "errors_test.TestWrapLazyf", errors.ToMap(err)["excFuncName"])
+
+	// The message is retained when fields are added later
+	err = errors.AddFields(err, errors.Fields{"key": "value"})
+	assert.Equal(t, "while reading 'config.yaml' attempt 3: EOF", err.Error())
+	assert.Equal(t, 1, calls)
+
+	assert.Nil(t, errors.WrapLazyf(nil, "never %s", func() []any {
+		t.Fatal("args must not be called for a nil error")
+		return nil
+	}))
+}
@@ -0,0 +1,138 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mailgun/errors/callstack"
+)
+
+// WrapAll returns an error annotating all the provided errors with the fields, a stack
+// trace and the message, for batch operations where more than one operation failed.
+// The returned error implements `Unwrap() []error` such that Is() and As() match any
+// of the errors, and the fields of every error are available from ToMap().
+//
+//	return errors.Fields{"batch": id}.WrapAll("while sending batch", errs...)
+//
+// Nil errors are discarded, if all errors are nil, WrapAll returns nil.
+func (f Fields) WrapAll(msg string, errs ...error) error {
+	var wrapped []error
+	for _, err := range errs {
+		if err != nil {
+			wrapped = append(wrapped, err)
+		}
+	}
+	if len(wrapped) == 0 {
+		return nil
+	}
+	return observeWrap(&fieldsJoin{
+		Trace:   callstack.Capture(1),
+		created: NowFunc(),
+		fields:  f,
+		wrapped: wrapped,
+		msg:     msg,
+	})
+}
+
+// fieldsJoin is returned by Fields.WrapAll()
+type fieldsJoin struct {
+	fields  Fields
+	msg     string
+	wrapped []error
+	callstack.Trace
+	created time.Time
+}
+
+func (c *fieldsJoin) Unwrap() []error {
+	return c.wrapped
+}
+
+func (c *fieldsJoin) ownFields() map[string]any { return c.fields }
+
+func (c *fieldsJoin) message() string { return c.msg }
+
+func (c *fieldsJoin) clone() error {
+	cp := *c
+	cp.fields = Fields(nil).Merge(c.fields)
+	cp.wrapped = cloneAll(c.wrapped)
+	return &cp
+}
+
+func (c *fieldsJoin) stripStack() error {
+	cp := *c
+	cp.Trace = callstack.Trace{}
+	cp.wrapped = stripAll(c.wrapped)
+	return &cp
+}
+
+// Cause returns the first of the wrapped errors, as github.com/pkg/errors.Cause()
+// can only follow a single error. We only support this because some code depends
+// on github.com/pkg/errors.Cause() returning the cause of the error.
+// Deprecated: use error.Is() or error.As() instead
+func (c *fieldsJoin) Cause() error { return c.wrapped[0] }
+
+func (c *fieldsJoin) Is(target error) bool {
+	_, ok := target.(*fieldsJoin)
+	return ok && MatchWrapperType
+}
+
+func (c *fieldsJoin) Error() string {
+	msgs := make([]string, len(c.wrapped))
+	for i, err := range c.wrapped {
+		msgs[i] = err.Error()
+	}
+	if c.msg == NoMsg {
+		return limitMsg(strings.Join(msgs, "; "))
+	}
+	return limitMsg(c.msg + ": " + strings.Join(msgs, "; "))
+}
+
+// StackTrace returns the stack trace of the first error which has one, as
+// it is closer to the cause, otherwise the stack trace captured by WrapAll().
+func (c *fieldsJoin) StackTrace() callstack.StackTrace {
+	for _, err := range c.wrapped {
+		var child callstack.HasStackTrace
+		if As(err, &child) {
+			return child.StackTrace()
+		}
+	}
+	return c.Trace.StackTrace()
+}
+
+func (c *fieldsJoin) CreatedAt() time.Time {
+	return c.created
+}
+
+// HasFields returns the fields of every wrapped error merged with the fields provided
+// to WrapAll(). Fields of the wrapped errors have precedence as they are closer to the
+// cause, and when more than one wrapped error has the same key, the first one wins.
+func (c *fieldsJoin) HasFields() map[string]any {
+	return mergeFields(c)
+}
+
+func (c *fieldsJoin) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		c.writePlus(s)
+		return
+	}
+	_, _ = io.WriteString(s, MessageOf(c))
+}
+
+func (c *fieldsJoin) writePlus(w io.Writer) bool {
+	_, _ = io.WriteString(w, c.msg)
+	if len(c.fields) != 0 {
+		f := fields{fields: setOf(c.fields)}
+		_, _ = fmt.Fprintf(w, " (%s)", f.FormatFields())
+	}
+	tree(c.wrapped).writePlus(w)
+	// The stack trace of the first branch which has one is included by the tree
+	for _, err := range c.wrapped {
+		var child callstack.HasStackTrace
+		if As(err, &child) {
+			return true
+		}
+	}
+	return false
+}
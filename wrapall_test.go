@@ -0,0 +1,36 @@
+package errors_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mailgun/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapAll(t *testing.T) {
+	errEOF := errors.Fields{"item": 1, "shared": "first"}.Wrap(io.EOF, "item 1")
+	errPipe := errors.Fields{"item.2": 2, "shared": "second"}.Wrap(io.ErrClosedPipe, "item 2")
+
+	err := errors.Fields{"batch": "b1", "shared": "batch"}.WrapAll("while sending batch", errEOF, nil, errPipe)
+	require.Error(t, err)
+	assert.Equal(t, "while sending batch: item 1: EOF; item 2: io: read/write on closed pipe", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.True(t, errors.Is(err, io.ErrClosedPipe))
+
+	m := errors.ToMap(err)
+	assert.Equal(t, "b1", m["batch"])
+	assert.Equal(t, 1, m["item"])
+	assert.Equal(t, 2, m["item.2"])
+	assert.Equal(t, "first", m["shared"])
+	assert.Equal(t, "errors_test.TestWrapAll", m["excFuncName"])
+	assert.Equal(t, 14, m["excLineNum"])
+
+	assert.Regexp(t, `^while sending batch \(batch=b1\)\n  - item 1: EOF \(.*\)\n    \S+TestWrapAll\n(?s:.*)`+
+		`\n  - item 2: io: read/write on closed pipe \(.*\)\n    \S+TestWrapAll\n`,
+		fmt.Sprintf("%+v", errors.Fields{"batch": "b1"}.WrapAll("while sending batch", errEOF, errPipe)))
+
+	assert.NoError(t, errors.Fields{"batch": "b1"}.WrapAll("while sending batch", nil, nil))
+}